@@ -0,0 +1,84 @@
+// Command import imports questions from a CSV, JSON or Open Trivia DB file into the database of the .env file.
+//
+//	go run ./cmd/import -file questions.csv           # dry run
+//	go run ./cmd/import -file questions.csv -commit   # save the questions
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"github.com/acha-bill/quizzer_backend/packages/questionimport"
+	"github.com/joho/godotenv"
+	"github.com/labstack/gommon/log"
+)
+
+func main() {
+	file := flag.String("file", "", "file to import")
+	format := flag.String("format", "", "csv, json or opentdb, guessed from the file if empty")
+	commit := flag.Bool("commit", false, "save the questions instead of doing a dry run")
+	asJSON := flag.Bool("json", false, "print the result as JSON")
+	flag.Parse()
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Fatal(err.Error())
+	}
+	data, err := ioutil.ReadFile(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f := questionimport.Format(*format)
+	if f == "" {
+		f = questionimport.DetectFormat(*file, data)
+	}
+	rows, err := questionimport.Parse(f, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err = mongodb.Connect(); err != nil {
+		log.Fatal(err)
+	}
+	result, err := questionimport.Import(rows, !*commit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printResult(result)
+	}
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printResult(result *questionimport.Result) {
+	for _, row := range result.Rows {
+		fmt.Printf("%5d  %-9s  %s", row.Line, row.Status, row.Question)
+		if row.Error != "" {
+			fmt.Printf(" (%s)", row.Error)
+		}
+		fmt.Println()
+	}
+	for _, name := range result.CategoriesCreated {
+		fmt.Printf("new category: %s\n", name)
+	}
+	fmt.Printf("%d rows, %d imported, %d failed\n", result.Total, result.Imported, result.Failed)
+	if result.DryRun {
+		fmt.Println("dry run, nothing was saved. Run again with -commit to import.")
+	}
+}
@@ -4,32 +4,43 @@ import (
 	"os"
 	"strings"
 
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
 )
 
+// JWTCustomClaims are the claims of our access tokens
 type JWTCustomClaims struct {
-	Username string `json:"username"`
-	IsAdmin  bool   `json:"isAdmin"`
-	Id       string `json:"_id"`
+	Username string    `json:"username"`
+	Role     rbac.Role `json:"role"`
+	Id       string    `json:"_id"`
+	Family   string    `json:"fam"`
+	MFA      bool      `json:"mfa,omitempty"`
 	jwt.StandardClaims
 }
 
-// IsAdmin returns true if the user is an admin
-func IsAdmin(ctx echo.Context) bool {
-	user := ctx.Get("user").(*jwt.Token)
-	claims := user.Claims.(JWTCustomClaims)
-	return claims.IsAdmin
+// GetClaims returns the claims of the token of the user holding this context
+func GetClaims(ctx echo.Context) *JWTCustomClaims {
+	return ctx.Get("user").(*JWTCustomClaims)
+}
+
+// Can returns true if the user holding this context has the permission
+func Can(ctx echo.Context, permission rbac.Permission) bool {
+	return rbac.Can(GetClaims(ctx).Role, permission)
 }
 
 // GetUsername returns the username of the user holding this context
 func GetUsername(ctx echo.Context) string {
-	user := ctx.Get("user").(*jwt.Token)
-	claims := user.Claims.(JWTCustomClaims)
-	return claims.Username
+	return GetClaims(ctx).Username
 }
 
 // IsDevelopment returns true if the server is running in dev mode.
 func IsDevelopment() bool {
 	return strings.HasPrefix(os.Getenv("ENV"), "d")
 }
+
+// RequireAdmin2FA returns true if privileged users must use two-factor authentication
+// to use their privileges.
+func RequireAdmin2FA() bool {
+	return os.Getenv("REQUIRE_ADMIN_2FA") == "true"
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrInvalidPagination is returned when page or limit are not positive numbers
+var ErrInvalidPagination = errors.New("page and limit must be positive numbers")
+
+// Pagination reads the page and limit query parameters.
+// page starts at 1 and limit is capped at maxLimit.
+func Pagination(ctx echo.Context, defaultLimit int64, maxLimit int64) (page int64, limit int64, err error) {
+	page, limit = 1, defaultLimit
+	if p := ctx.QueryParam("page"); p != "" {
+		if page, err = strconv.ParseInt(p, 10, 64); err != nil || page < 1 {
+			return 0, 0, ErrInvalidPagination
+		}
+	}
+	if l := ctx.QueryParam("limit"); l != "" {
+		if limit, err = strconv.ParseInt(l, 10, 64); err != nil || limit < 1 {
+			return 0, 0, ErrInvalidPagination
+		}
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return page, limit, nil
+}
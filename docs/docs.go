@@ -33,8 +33,205 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/login": {
+        "/admin/audit": {
+            "get": {
+                "description": "username limits the entries to those where the user is the actor or the target.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "list the audit log, most recent first",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "entries per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ListAuditResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users": {
+            "get": {
+                "description": "q matches part of the username, display name or email, ignoring case.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "list and search users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "search",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "users per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ListUsersResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{username}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "get the details of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.UserDetailsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.UserDetailsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{username}/logout": {
+            "post": {
+                "description": "Revokes every session of the user and closes their sockets.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "log a user out everywhere",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.UserResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.UserResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{username}/password": {
+            "post": {
+                "description": "Replaces the password with a random temporary one that is returned once,\nand logs the user out everywhere. Share it with the user over a trusted channel.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "reset the password of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ResetPasswordResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ResetPasswordResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ResetPasswordResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/confirm": {
             "post": {
+                "description": "Returns the recovery codes, which are only shown once, and new tokens that carry the second factor.",
                 "consumes": [
                     "application/json"
                 ],
@@ -44,15 +241,15 @@ var doc = `{
                 "tags": [
                     "Auth"
                 ],
-                "summary": "Login user",
+                "summary": "confirm the two-factor enrolment with a first code",
                 "parameters": [
                     {
-                        "description": "login",
-                        "name": "login",
+                        "description": "confirm",
+                        "name": "confirm",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/auth.LoginRequest"
+                            "$ref": "#/definitions/auth.TwoFactorCodeRequest"
                         }
                     }
                 ],
@@ -60,13 +257,13 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/auth.LoginResponse"
+                            "$ref": "#/definitions/auth.ConfirmTwoFactorResponse"
                         }
                     }
                 }
             }
         },
-        "/auth/register": {
+        "/auth/2fa/disable": {
             "post": {
                 "consumes": [
                     "application/json"
@@ -77,30 +274,50 @@ var doc = `{
                 "tags": [
                     "Auth"
                 ],
-                "summary": "register user",
+                "summary": "turn off two-factor authentication",
                 "parameters": [
                     {
-                        "description": "register",
-                        "name": "register",
+                        "description": "disable",
+                        "name": "disable",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/auth.RegisterRequest"
+                            "$ref": "#/definitions/auth.TwoFactorCodeRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/auth.RegisterResponse"
+                            "$ref": "#/definitions/auth.TwoFactorResponse"
                         }
                     }
                 }
             }
         },
-        "/category/": {
-            "get": {
+        "/auth/2fa/enroll": {
+            "post": {
+                "description": "Returns the secret as an otpauth URI and as a QR code. Confirm with /auth/2fa/confirm.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "start enrolling in two-factor authentication",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.EnrollTwoFactorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/recovery-codes": {
+            "post": {
                 "consumes": [
                     "application/json"
                 ],
@@ -108,18 +325,31 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Category"
+                    "Auth"
+                ],
+                "summary": "replace the recovery codes",
+                "parameters": [
+                    {
+                        "description": "codes",
+                        "name": "codes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.TwoFactorCodeRequest"
+                        }
+                    }
                 ],
-                "summary": "list all categories",
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/category.FindCategoryResponse"
+                            "$ref": "#/definitions/auth.ConfirmTwoFactorResponse"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/auth/2fa/verify": {
             "post": {
                 "consumes": [
                     "application/json"
@@ -128,31 +358,49 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Category"
+                    "Auth"
                 ],
-                "summary": "create category",
+                "summary": "finish logging in with a two-factor or recovery code",
                 "parameters": [
                     {
-                        "description": "create",
-                        "name": "question",
+                        "description": "verify",
+                        "name": "verify",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/category.CreateCategoryRequest"
+                            "$ref": "#/definitions/auth.VerifyTwoFactorRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
                         "schema": {
-                            "$ref": "#/definitions/category.CreateCategoryResponse"
+                            "$ref": "#/definitions/auth.LoginResponse"
                         }
                     }
                 }
             }
         },
-        "/category/:id": {
+        "/auth/email": {
             "put": {
                 "consumes": [
                     "application/json"
@@ -161,32 +409,38 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Category"
+                    "Auth"
                 ],
-                "summary": "edit category",
+                "summary": "set the email of the current user and send a verification link to it",
                 "parameters": [
                     {
-                        "description": "create",
-                        "name": "question",
+                        "description": "email",
+                        "name": "email",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/category.EditCategoryRequest"
+                            "$ref": "#/definitions/auth.SetEmailRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.EmailResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
-                            "$ref": "#/definitions/category.EditCategoryResponse"
+                            "$ref": "#/definitions/auth.EmailResponse"
                         }
                     }
                 }
             }
         },
-        "/question/": {
-            "get": {
+        "/auth/email/verify": {
+            "post": {
                 "consumes": [
                     "application/json"
                 ],
@@ -194,19 +448,33 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Question"
+                    "Auth"
+                ],
+                "summary": "verify an email address with the token sent to it",
+                "parameters": [
+                    {
+                        "description": "verify",
+                        "name": "verify",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.TokenRequest"
+                        }
+                    }
                 ],
-                "summary": "list all questions",
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/question.FindQuestionsResponse"
+                            "$ref": "#/definitions/auth.EmailResponse"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/auth/forgot": {
             "post": {
+                "description": "The response is the same whether or not the user exists.",
                 "consumes": [
                     "application/json"
                 ],
@@ -214,32 +482,53 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Question"
+                    "Auth"
                 ],
-                "summary": "create question",
+                "summary": "send a password reset link to the verified email of a user",
                 "parameters": [
                     {
-                        "description": "create",
-                        "name": "question",
+                        "description": "forgot",
+                        "name": "forgot",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/question.CreateQuestionRequest"
+                            "$ref": "#/definitions/auth.ForgotPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ForgotPasswordResponse"
                         }
                     }
+                }
+            }
+        },
+        "/auth/guest": {
+            "post": {
+                "description": "Creates a temporary account. Claim it with /auth/guest/claim to keep it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
                 ],
+                "summary": "play as a guest",
                 "responses": {
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/question.CreateQuestionResponse"
+                            "$ref": "#/definitions/auth.GuestResponse"
                         }
                     }
                 }
             }
         },
-        "/search": {
-            "get": {
+        "/auth/guest/claim": {
+            "post": {
+                "description": "The guest keeps its history. The tokens of the guest are revoked and new ones are returned.",
                 "consumes": [
                     "application/json"
                 ],
@@ -247,189 +536,3651 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Search"
+                    "Auth"
+                ],
+                "summary": "turn the current guest account into a regular account",
+                "parameters": [
+                    {
+                        "description": "claim",
+                        "name": "claim",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.ClaimGuestRequest"
+                        }
+                    }
                 ],
-                "summary": "search for a random opponent",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "Object"
+                            "$ref": "#/definitions/auth.GuestResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/auth.GuestResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/auth/lockout/{username}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "get the login lockout state of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LockoutResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "clear the login lockout of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LockoutResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/login": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "description": "login",
+                        "name": "login",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/logout": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "logout and revoke the current tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LogoutResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/callback": {
+            "get": {
+                "description": "The external identity is linked to the user with the same verified email, or to a new user.\nRedirects to the /oidc-login page of the app with a one-time code in the token parameter,\nwhich the app exchanges for tokens with /auth/oidc/token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "finish logging in with the external identity provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "state",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/login": {
+            "get": {
+                "description": "Redirects to the provider. The provider redirects back to /auth/oidc/callback.\nThe state of the login is also kept in a cookie, so that only this browser can finish it.",
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "start logging in with the external identity provider",
+                "responses": {
+                    "302": {}
+                }
+            }
+        },
+        "/auth/oidc/token": {
+            "post": {
+                "description": "The code can only be used once and expires after a minute.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "exchange the code of a finished external login for tokens",
+                "parameters": [
+                    {
+                        "description": "code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.TokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "exchange a refresh token for a new token pair",
+                "parameters": [
+                    {
+                        "description": "refresh",
+                        "name": "refresh",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/auth.LoginResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/register": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "register user",
+                "parameters": [
+                    {
+                        "description": "register",
+                        "name": "register",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RegisterResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RegisterErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/reset": {
+            "post": {
+                "description": "Every session of the user is logged out.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "set a new password with the token from a password reset email",
+                "parameters": [
+                    {
+                        "description": "reset",
+                        "name": "reset",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.ResetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ResetPasswordResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/role/{username}": {
+            "put": {
+                "description": "The new role is used in the access tokens issued after the change.\nA user who loses permissions is logged out everywhere, so that their tokens stop granting them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "change the role of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.SetRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.SetRoleResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/roles": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "list the roles and the permissions they grant",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ListRolesResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions": {
+            "get": {
+                "description": "Each login starts a session that lasts as long as its refresh token.\nconnected is true if the session holds a live socket.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "list the active sessions of the user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.ListSessionsResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "revoke every session of the user, including the current one",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RevokeSessionsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions/{id}": {
+            "delete": {
+                "description": "The tokens of the session stop working and its socket is closed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "revoke a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "session id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RevokeSessionsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/auth.RevokeSessionsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/ws-ticket": {
+            "post": {
+                "description": "The ticket is valid for 30 seconds and can be used once, from the origin of this request.\nOpen the socket with /ws?ticket=\u003cticket\u003e.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "get a ticket to open a socket",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/auth.WsTicketResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/category/": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "list all categories",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/category.FindCategoryResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "The name must not be empty or already used by another category, ignoring case.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "create category",
+                "parameters": [
+                    {
+                        "description": "create",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/category.CreateCategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/category/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "get a category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "category id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "rename a category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "category id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "edit",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/category.EditCategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Only categories without questions can be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "delete a category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "category id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/category.CategoryResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/challenge/{id}/accept": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Challenge"
+                ],
+                "summary": "accept a challenge and start the game",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "challenge id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/challenge/{id}/decline": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Challenge"
+                ],
+                "summary": "decline a challenge, or cancel one that was sent",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "challenge id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/challenge/{username}": {
+            "post": {
+                "description": "Both users must be connected to the socket and the challenged user must not be in a game.\nThe challenged user gets a challenge message and has 30 seconds to accept it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Challenge"
+                ],
+                "summary": "challenge a user to a game",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/challenge.ChallengeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend/": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Friend"
+                ],
+                "summary": "list friends with their presence, and pending friend requests",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/friend.ListFriendsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend/accept/{username}": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Friend"
+                ],
+                "summary": "accept a friend request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend/decline/{username}": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Friend"
+                ],
+                "summary": "decline a friend request, or cancel one that was sent",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend/request/{username}": {
+            "post": {
+                "description": "If the other user has already sent a request, it is accepted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Friend"
+                ],
+                "summary": "send a friend request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/friend/{username}": {
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Friend"
+                ],
+                "summary": "remove a friend",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/friend.FriendshipResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/blocks": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "list the users blocked by the user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ListBlocksResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/blocks/{username}": {
+            "post": {
+                "description": "Blocked users are not matched together, cannot challenge or befriend each other\nand cannot see each other's profile. Blocking removes the friendship.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "block a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.BlockResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.BlockResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "unblock a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.BlockResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.BlockResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/reports": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "list reports in the moderation queue, oldest first",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "open (default), resolved, dismissed or all",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "reports per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ListReportsResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "gameId optionally references a game both players took part in.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "report a player",
+                "parameters": [
+                    {
+                        "description": "report",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/moderation.CreateReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReportResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReportResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/reports/{id}/review": {
+            "post": {
+                "description": "Acting on the reported account is done separately with the suspend and ban endpoints of /admin.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "close a report as resolved or dismissed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "report id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "review",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReviewReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReportResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReportResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/moderation.ReportResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/users/{username}/ban": {
+            "post": {
+                "description": "The user is logged out everywhere and their sockets are closed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "ban a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "ban",
+                        "name": "ban",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.BanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/users/{username}/suspend": {
+            "post": {
+                "description": "The user is logged out everywhere and their sockets are closed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "suspend a user until a time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "suspend",
+                        "name": "suspend",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.SuspendRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/moderation/users/{username}/unban": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "lift the ban or suspension of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.AccountStatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/question/": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "list all questions",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "category ids or names",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "easy, medium or hard",
+                        "name": "difficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "questions with any of the tags",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created at or after, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created before, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.FindQuestionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/question.FindQuestionsResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "The question and answers must not be empty, there must be at least two distinct answers\nand the correct answer must be one of them. The category is optional but must exist.\nThe difficulty is easy, medium or hard and defaults to medium. Harder questions score more.\nMulti-select questions have correctAnswers among the answers, numeric questions a numericAnswer,\nand free text questions a correctAnswer with optional aliases but no answers.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "create question",
+                "parameters": [
+                    {
+                        "description": "create",
+                        "name": "question",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/question.CreateQuestionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/question/export": {
+            "get": {
+                "description": "The format is given by the format parameter, or else negotiated from the Accept header\n(application/json, text/csv or application/yaml) and defaults to JSON.\nExports use the schema of the import, so that they can be imported back.",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "export the questions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "json, csv or yaml",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "category ids or names",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "easy, medium or hard",
+                        "name": "difficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "questions with any of the tags",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created at or after, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created before, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/question.ExportedQuestion"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/question/import": {
+            "post": {
+                "description": "The file is sent as the body or as the \"file\" field of a multipart form. Its format is csv\n(columns question, answers separated by \"|\", correctAnswer and category), json (an array of\nquestions) or opentdb (an Open Trivia DB response), and is guessed if not given.\nImports are dry runs unless dryRun=false: every row is validated and reported, but nothing is saved.\nCategories are matched by name and missing ones are created. Duplicate questions are skipped.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "import questions from a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv, json or opentdb",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "dry run, true by default",
+                        "name": "dryRun",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.ImportQuestionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/question.ImportQuestionsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/question/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "get a question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "question id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "The question is replaced and validated like a new one. Its media is kept.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "edit a question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "question id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "edit",
+                        "name": "question",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/question.CreateQuestionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "The media of the question is deleted with it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "delete a question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "question id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/question/{id}/media": {
+            "post": {
+                "description": "The type of the file is read from its content. Images are PNG, JPEG, GIF or WebP files of at most 5MB,\nsounds are MP3, Ogg or WAV files of at most 10MB. The media replaces the one the question had.\nMedia are only served at signed URLs, which expire after an hour.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "attach an image or a sound to a question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "question id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "image or sound",
+                        "name": "media",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Question"
+                ],
+                "summary": "remove the image or sound of a question",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "question id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/question.QuestionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "description": "Players are only matched with players who want a category in common, or any category.\nThe questions of the game are drawn from the categories they share, or from the categories of both\nplayers if those they share hold too few questions.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Search"
+                ],
+                "summary": "search for a random opponent",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "category ids, any category if empty",
+                        "name": "category",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/search.SearchOpponentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/search.SearchOpponentResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "get the profile of the user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.ProfileResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Fields that are omitted are left unchanged. An empty profileURL removes the avatar.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "update the profile of the user",
+                "parameters": [
+                    {
+                        "description": "profile",
+                        "name": "profile",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.UpdateProfileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.ProfileResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Accounts with a password must confirm it. The user is logged out everywhere, their profile,\navatar, sessions, friends and blocks are deleted, and their name in game history is replaced\nwith a tombstone so that the statistics of other players stay the same.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "delete the account of the user",
+                "parameters": [
+                    {
+                        "description": "confirm",
+                        "name": "confirm",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.DeleteAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.DeleteAccountResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/user.DeleteAccountResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/user.DeleteAccountResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/avatar": {
+            "post": {
+                "description": "The image is cropped to a square and resized to 256x256 pixels.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "upload the avatar of the user",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "PNG, JPEG or GIF image of at most 5MB",
+                        "name": "avatar",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.ProfileResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/user.ProfileResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/user.ProfileResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/export": {
+            "get": {
+                "description": "Returns a zip archive with one JSON file per section: profile, sessions, friends, blocks, games and reports.\nUse format=json to get a single JSON document instead.",
+                "produces": [
+                    "application/zip",
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "export everything stored about the user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "zip (default) or json",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.DataExport"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{username}": {
+            "get": {
+                "description": "winRate is wins over games played. accuracy is correct answers over questions asked.\nPlayers that blocked each other cannot see each other's profile.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "get the public profile and statistics of a player",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.PublicProfileResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/user.PublicProfileResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "admin.AccountStatusResponse": {
+            "type": "object",
+            "properties": {
+                "banned": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "suspendedUntil": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.AuditEntryResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.BanRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.ListAuditResponse": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.AuditEntryResponse"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                }
+            }
+        },
+        "admin.ListRolesResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.RoleResponse"
+                    }
+                }
+            }
+        },
+        "admin.ListUsersResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.UserResponse"
+                    }
+                }
+            }
+        },
+        "admin.ResetPasswordResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "temporaryPassword": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.RoleResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.SessionResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "userAgent": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.SetRoleRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.SetRoleResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.SuspendRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "until": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.UserDetailsResponse": {
+            "type": "object",
+            "properties": {
+                "banned": {
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "emailVerified": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failedLogins": {
+                    "type": "integer"
+                },
+                "identities": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "isGuest": {
+                    "type": "boolean"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "lockedUntil": {
+                    "type": "string"
+                },
+                "presence": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.SessionResponse"
+                    }
+                },
+                "suspendReason": {
+                    "type": "string"
+                },
+                "suspendedUntil": {
+                    "type": "string"
+                },
+                "twoFactorEnabled": {
+                    "type": "boolean"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.UserResponse": {
+            "type": "object",
+            "properties": {
+                "banned": {
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "emailVerified": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "isGuest": {
+                    "type": "boolean"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "suspendReason": {
+                    "type": "string"
+                },
+                "suspendedUntil": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ClaimGuestRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ConfirmTwoFactorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "login": {
+                    "type": "object",
+                    "$ref": "#/definitions/auth.LoginResponse"
+                },
+                "recoveryCodes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "auth.EmailResponse": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "emailVerified": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.EnrollTwoFactorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "qrCode": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ForgotPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "login": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ForgotPasswordResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.GuestResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "refreshToken": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ListSessionsResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.SessionResponse"
+                    }
+                }
+            }
+        },
+        "auth.LockoutResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "failedLogins": {
+                    "type": "integer"
+                },
+                "lastFailedLogin": {
+                    "type": "string"
+                },
+                "locked": {
+                    "type": "boolean"
+                },
+                "lockedUntil": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
         "auth.LoginRequest": {
             "type": "object",
             "properties": {
-                "password": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.LoginResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "mfaRequired": {
+                    "type": "boolean"
+                },
+                "mfaToken": {
+                    "type": "string"
+                },
+                "refreshToken": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.LogoutResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refreshToken": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.RegisterErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.RegisterRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "profileURL": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.RegisterResponse": {
+            "$ref": "#/definitions/models.User"
+        },
+        "auth.ResetPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.ResetPasswordResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.RevokeSessionsResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "integer"
+                }
+            }
+        },
+        "auth.SessionResponse": {
+            "type": "object",
+            "properties": {
+                "connected": {
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "userAgent": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.SetEmailRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.TokenRequest": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.TwoFactorCodeRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "recoveryCode": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.TwoFactorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.VerifyTwoFactorRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "mfaToken": {
+                    "type": "string"
+                },
+                "recoveryCode": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.WsTicketResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "ticket": {
+                    "type": "string"
+                }
+            }
+        },
+        "category.CategoryResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "category.CreateCategoryRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "category.EditCategoryRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "category.FindCategoryResponse": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/category.CategoryResponse"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "challenge.ChallengeResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "friend.FriendResponse": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string"
+                },
+                "presence": {
+                    "type": "string"
+                },
+                "profileURL": {
+                    "type": "string"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "friend.FriendshipResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "presence": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "friend.ListFriendsResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "friends": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/friend.FriendResponse"
+                    }
+                },
+                "incoming": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/friend.FriendResponse"
+                    }
+                },
+                "outgoing": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/friend.FriendResponse"
+                    }
+                }
+            }
+        },
+        "models.ExternalIdentity": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "issuer": {
+                    "type": "string"
+                },
+                "linkedAt": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.User": {
+            "type": "object",
+            "properties": {
+                "banned": {
+                    "type": "boolean"
+                },
+                "bio": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "emailVerified": {
+                    "type": "boolean"
+                },
+                "emailVerifiedAt": {
+                    "type": "string"
+                },
+                "failedLogins": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "identities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ExternalIdentity"
+                    }
+                },
+                "isGuest": {
+                    "type": "boolean"
+                },
+                "isSearching": {
+                    "type": "boolean"
+                },
+                "lastFailedLogin": {
+                    "type": "string"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "lockedUntil": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "profileURL": {
+                    "type": "string"
+                },
+                "recoveryCodes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "role": {
+                    "type": "string"
+                },
+                "suspendReason": {
+                    "type": "string"
+                },
+                "suspendedUntil": {
+                    "type": "string"
+                },
+                "totpenabled": {
+                    "type": "boolean"
+                },
+                "totplastStep": {
+                    "type": "integer"
+                },
+                "totpsecret": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "moderation.BlockResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "moderation.CreateReportRequest": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "string"
+                },
+                "gameId": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "enum": [
+                        "cheating",
+                        "harassment",
+                        "offensive_name",
+                        "spam",
+                        "other"
+                    ]
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "moderation.ListBlocksResponse": {
+            "type": "object",
+            "properties": {
+                "blocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/moderation.BlockResponse"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "moderation.ListReportsResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "reports": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/moderation.ReportResponse"
+                    }
+                }
+            }
+        },
+        "moderation.ReportResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "gameId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reported": {
+                    "type": "string"
+                },
+                "reporter": {
+                    "type": "string"
+                },
+                "reviewNote": {
+                    "type": "string"
+                },
+                "reviewedAt": {
+                    "type": "string"
+                },
+                "reviewer": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "moderation.ReviewReportRequest": {
+            "type": "object",
+            "properties": {
+                "note": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "resolved",
+                        "dismissed"
+                    ]
+                }
+            }
+        },
+        "question.CreateQuestionRequest": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "description": "Aliases are other accepted answers of a free text question",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "answers": {
+                    "description": "Answers are the options of single choice, true/false and multi-select questions.\nThey default to True and False for true/false questions.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "categoryId": {
+                    "type": "string"
+                },
+                "correctAnswer": {
+                    "description": "CorrectAnswer is the answer of single choice, true/false and free text questions",
+                    "type": "string"
+                },
+                "correctAnswers": {
+                    "description": "CorrectAnswers are the correct options of a multi-select question",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "difficulty": {
+                    "description": "Difficulty is easy, medium or hard. Questions without one are medium.",
+                    "type": "string"
+                },
+                "numericAnswer": {
+                    "description": "NumericAnswer is the answer of a numeric question",
+                    "type": "number"
+                },
+                "question": {
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Tags are stored lower cased",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "description": "Type is single (the default), true_false, multi, numeric or text",
+                    "type": "string"
+                }
+            }
+        },
+        "question.ExportedQuestion": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "answers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category": {
+                    "type": "string"
+                },
+                "correctAnswer": {
+                    "type": "string"
+                },
+                "correctAnswers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "difficulty": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "numericAnswer": {
+                    "type": "number"
+                },
+                "question": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "question.FindQuestionsResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "questions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/question.QuestionResponse"
+                    }
+                }
+            }
+        },
+        "question.ImportQuestionsResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "object",
+                    "$ref": "#/definitions/questionimport.Result"
+                }
+            }
+        },
+        "question.MediaResponse": {
+            "type": "object",
+            "properties": {
+                "contentType": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL is signed and expires after an hour",
+                    "type": "string"
+                }
+            }
+        },
+        "question.QuestionResponse": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "answers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category": {
+                    "type": "string"
+                },
+                "categoryId": {
+                    "type": "string"
+                },
+                "correctAnswer": {
+                    "type": "string"
+                },
+                "correctAnswers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "difficulty": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "media": {
+                    "type": "object",
+                    "$ref": "#/definitions/question.MediaResponse"
+                },
+                "numericAnswer": {
+                    "type": "number"
+                },
+                "question": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "questionimport.Result": {
+            "type": "object",
+            "properties": {
+                "categoriesCreated": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "imported": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/questionimport.RowResult"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "questionimport.RowResult": {
+            "type": "object",
+            "properties": {
+                "category": {
                     "type": "string"
                 },
-                "username": {
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "question": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
                 }
             }
         },
-        "auth.LoginResponse": {
+        "search.SearchOpponentResponse": {
             "type": "object",
             "properties": {
                 "error": {
                     "type": "string"
+                }
+            }
+        },
+        "user.DataExport": {
+            "type": "object",
+            "properties": {
+                "blocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportBlock"
+                    }
+                },
+                "exportedAt": {
+                    "type": "string"
                 },
-                "token": {
+                "friends": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportFriend"
+                    }
+                },
+                "games": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportGame"
+                    }
+                },
+                "profile": {
+                    "type": "object",
+                    "$ref": "#/definitions/user.ExportProfile"
+                },
+                "reports": {
+                    "type": "object",
+                    "$ref": "#/definitions/user.ExportReports"
+                },
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportSession"
+                    }
+                }
+            }
+        },
+        "user.DeleteAccountRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
                     "type": "string"
                 }
             }
         },
-        "auth.RegisterRequest": {
+        "user.DeleteAccountResponse": {
             "type": "object",
             "properties": {
-                "isAdmin": {
+                "deleted": {
                     "type": "boolean"
                 },
-                "password": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.ExportAnswer": {
+            "type": "object",
+            "properties": {
+                "answer": {
                     "type": "string"
                 },
-                "profileURL": {
+                "answered": {
+                    "type": "boolean"
+                },
+                "category": {
                     "type": "string"
                 },
-                "username": {
+                "correct": {
+                    "type": "boolean"
+                },
+                "questionId": {
                     "type": "string"
+                },
+                "responseMillis": {
+                    "type": "integer"
                 }
             }
         },
-        "auth.RegisterResponse": {
-            "$ref": "#/definitions/models.User"
-        },
-        "category.CreateCategoryRequest": {
+        "user.ExportBlock": {
             "type": "object",
             "properties": {
-                "name": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "category.CreateCategoryResponse": {
+        "user.ExportFriend": {
             "type": "object",
             "properties": {
-                "category": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.Category"
+                "acceptedAt": {
+                    "type": "string"
                 },
-                "error": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "requested": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "category.EditCategoryRequest": {
+        "user.ExportGame": {
             "type": "object",
             "properties": {
+                "answers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportAnswer"
+                    }
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "forfeit": {
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "string"
                 },
-                "name": {
+                "lost": {
+                    "type": "boolean"
+                },
+                "opponents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportOpponent"
+                    }
+                },
+                "score": {
+                    "type": "number"
+                },
+                "startedAt": {
                     "type": "string"
+                },
+                "winner": {
+                    "type": "string"
+                },
+                "won": {
+                    "type": "boolean"
                 }
             }
         },
-        "category.EditCategoryResponse": {
+        "user.ExportIdentity": {
             "type": "object",
             "properties": {
-                "category": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.Category"
+                "email": {
+                    "type": "string"
                 },
-                "error": {
+                "issuer": {
+                    "type": "string"
+                },
+                "linkedAt": {
+                    "type": "string"
+                },
+                "subject": {
                     "type": "string"
                 }
             }
         },
-        "category.FindCategoryResponse": {
+        "user.ExportOpponent": {
             "type": "object",
             "properties": {
-                "categories": {
+                "score": {
+                    "type": "number"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.ExportProfile": {
+            "type": "object",
+            "properties": {
+                "banned": {
+                    "type": "boolean"
+                },
+                "bio": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "emailVerified": {
+                    "type": "boolean"
+                },
+                "identities": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Category"
+                        "$ref": "#/definitions/user.ExportIdentity"
                     }
                 },
-                "error": {
+                "isGuest": {
+                    "type": "boolean"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "profileURL": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "suspendReason": {
+                    "type": "string"
+                },
+                "suspendedUntil": {
+                    "type": "string"
+                },
+                "twoFactorEnabled": {
+                    "type": "boolean"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "models.Category": {
+        "user.ExportReport": {
             "type": "object",
             "properties": {
                 "createdAt": {
                     "type": "string"
                 },
+                "details": {
+                    "type": "string"
+                },
+                "gameId": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
-                "name": {
+                "reason": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "reported": {
+                    "type": "string"
+                },
+                "reviewedAt": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
                 }
             }
         },
-        "models.Question": {
+        "user.ExportReports": {
             "type": "object",
             "properties": {
-                "answers": {
+                "filed": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/user.ExportReport"
                     }
                 },
-                "category": {
-                    "type": "object",
-                    "$ref": "#/definitions/models.Category"
+                "received": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.ExportReport"
+                    }
+                }
+            }
+        },
+        "user.ExportSession": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
                 },
-                "correctAnswer": {
+                "expiresAt": {
                     "type": "string"
                 },
-                "createdAt": {
+                "ip": {
                     "type": "string"
                 },
-                "id": {
+                "lastSeenAt": {
                     "type": "string"
                 },
-                "question": {
+                "revokedAt": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "userAgent": {
                     "type": "string"
                 }
             }
         },
-        "models.User": {
+        "user.ProfileResponse": {
             "type": "object",
             "properties": {
+                "bio": {
+                    "type": "string"
+                },
                 "createdAt": {
                     "type": "string"
                 },
-                "id": {
+                "displayName": {
                     "type": "string"
                 },
-                "isAdmin": {
-                    "type": "boolean"
+                "email": {
+                    "type": "string"
                 },
-                "isSearching": {
+                "emailVerified": {
                     "type": "boolean"
                 },
-                "password": {
+                "error": {
                     "type": "string"
                 },
+                "isGuest": {
+                    "type": "boolean"
+                },
                 "profileURL": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "role": {
                     "type": "string"
                 },
                 "username": {
@@ -437,37 +4188,73 @@ var doc = `{
                 }
             }
         },
-        "question.CreateQuestionRequest": {
+        "user.PublicProfileResponse": {
             "type": "object",
             "properties": {
-                "answers": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "bio": {
+                    "type": "string"
                 },
-                "correctAnswer": {
+                "createdAt": {
                     "type": "string"
                 },
-                "question": {
+                "displayName": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "isGuest": {
+                    "type": "boolean"
+                },
+                "profileURL": {
+                    "type": "string"
+                },
+                "stats": {
+                    "type": "object",
+                    "$ref": "#/definitions/user.StatsResponse"
+                },
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "question.CreateQuestionResponse": {
-            "$ref": "#/definitions/models.Question"
+        "user.StatsResponse": {
+            "type": "object",
+            "properties": {
+                "accuracy": {
+                    "type": "number"
+                },
+                "favouriteCategory": {
+                    "type": "string"
+                },
+                "losses": {
+                    "type": "integer"
+                },
+                "meanResponseMillis": {
+                    "type": "number"
+                },
+                "played": {
+                    "type": "integer"
+                },
+                "winRate": {
+                    "type": "number"
+                },
+                "wins": {
+                    "type": "integer"
+                }
+            }
         },
-        "question.FindQuestionsResponse": {
+        "user.UpdateProfileRequest": {
             "type": "object",
             "properties": {
-                "error": {
+                "bio": {
                     "type": "string"
                 },
-                "questions": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Question"
-                    }
+                "displayName": {
+                    "type": "string"
+                },
+                "profileURL": {
+                    "type": "string"
                 }
             }
         }
@@ -19,4 +19,12 @@ export default class AuthApi {
             throw  e
         }
     }
+    async wsTicket() {
+        try{
+            let res = await this.api.post(`${baseUrl}/ws-ticket`)
+            return res.data
+        }catch(e){
+            throw  e
+        }
+    }
 }
\ No newline at end of file
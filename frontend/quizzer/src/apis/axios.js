@@ -10,7 +10,7 @@ export function getApi(token) {
     headers: {
       'Content-Type': 'application/json',
       Accept: 'application/json',
-      Authorization: `Bear ${token}`,
+      Authorization: `Bearer ${token}`,
     },
   });
   return instance;
@@ -0,0 +1,10 @@
+import apis from '../apis/apis'
+
+const wsURL = process.env.REACT_APP_WS_URL ? process.env.REACT_APP_WS_URL : 'ws://localhost:8081/ws'
+
+// openSocket opens an authenticated socket with a single-use ticket.
+// The ticket expires after 30 seconds so it is requested right before connecting.
+export default async function openSocket() {
+    const {ticket} = await apis.auth().wsTicket()
+    return new WebSocket(`${wsURL}?ticket=${encodeURIComponent(ticket)}`)
+}
@@ -1,9 +1,24 @@
 package main
 
 import (
+	auditLogService "github.com/acha-bill/quizzer_backend/packages/dblayer/auditlog"
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	friendshipService "github.com/acha-bill/quizzer_backend/packages/dblayer/friendship"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	oidcStateService "github.com/acha-bill/quizzer_backend/packages/dblayer/oidcstate"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	refreshTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/refreshtoken"
+	reportService "github.com/acha-bill/quizzer_backend/packages/dblayer/report"
+	revokedTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/revokedtoken"
+	sessionService "github.com/acha-bill/quizzer_backend/packages/dblayer/session"
+	usedTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/usedtoken"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	wsTicketService "github.com/acha-bill/quizzer_backend/packages/dblayer/wsticket"
 	"github.com/acha-bill/quizzer_backend/packages/mongodb"
 	"github.com/acha-bill/quizzer_backend/packages/server"
 	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/acha-bill/quizzer_backend/plugins/auth"
 	"github.com/joho/godotenv"
 	"github.com/labstack/gommon/log"
 )
@@ -44,13 +59,54 @@ func main() {
 		return
 	}
 
+	if err = token.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+	go token.WatchKeys()
+
 	_, err = mongodb.Connect()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err = ensureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	if err = userService.MigrateRoles(); err != nil {
+		log.Fatal(err)
+	}
+	if err = questionService.MigrateCategories(); err != nil {
+		log.Fatal(err)
+	}
+
+	go auth.CollectGuests()
+	go socketserver.WatchPresence()
 
 	e := server.Instance()
 	e.Static("/", "./public")
 	e.GET("/ws", socketserver.Listen)
 	e.Logger.Fatal(e.Start(":8081"))
 }
+
+// ensureIndexes creates the indexes needed by the collections
+func ensureIndexes() error {
+	for _, ensure := range []func() error{
+		userService.EnsureIndexes,
+		refreshTokenService.EnsureIndexes,
+		revokedTokenService.EnsureIndexes,
+		usedTokenService.EnsureIndexes,
+		oidcStateService.EnsureIndexes,
+		sessionService.EnsureIndexes,
+		wsTicketService.EnsureIndexes,
+		gameResultService.EnsureIndexes,
+		friendshipService.EnsureIndexes,
+		blockService.EnsureIndexes,
+		reportService.EnsureIndexes,
+		auditLogService.EnsureIndexes,
+		questionService.EnsureIndexes,
+	} {
+		if err := ensure(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
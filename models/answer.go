@@ -0,0 +1,203 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// numericTolerance is how far, relative to the right number, an answer to a numeric question can be and still win
+const numericTolerance = 0.1
+
+// maxTextAnswer is the length in bytes of the longest free text answer that is matched. Longer ones are wrong.
+const maxTextAnswer = 200
+
+// Answer is the answer of a player to a question. Which field is used depends on the type of the question:
+// Text for single choice, true/false and free text questions, Choices for multi-select questions
+// and Number for numeric questions.
+type Answer struct {
+	Text    string
+	Choices []string
+	Number  *float64
+}
+
+// String returns the answer as it is recorded in the history of games
+func (a Answer) String() string {
+	if len(a.Choices) > 0 {
+		choices := append([]string{}, a.Choices...)
+		sort.Strings(choices)
+		return strings.Join(choices, "|")
+	}
+	if a.Number != nil {
+		return strconv.FormatFloat(*a.Number, 'f', -1, 64)
+	}
+	return a.Text
+}
+
+// Credit returns how right the answer is, from 0 to 1:
+//   - single choice questions need the exact answer, true/false ones ignore case
+//   - multi-select questions give a share of the credit for each correct choice and take it back for each wrong one
+//   - numeric questions give full credit to the exact number. Whether a wrong number within the tolerance
+//     is the closest is up to the game, see InTolerance.
+//   - free text questions accept the answer or an alias, ignoring case, punctuation, leading articles and small typos
+func (q *Question) Credit(a Answer) float64 {
+	switch q.Kind() {
+	case QuestionTrueFalse:
+		if strings.EqualFold(strings.TrimSpace(a.Text), q.CorrectAnswer) {
+			return 1
+		}
+	case QuestionMulti:
+		if len(q.CorrectAnswers) == 0 {
+			return 0
+		}
+		right, wrong := 0, 0
+		seen := make(map[string]bool)
+		for _, choice := range a.Choices {
+			if seen[choice] {
+				continue
+			}
+			seen[choice] = true
+			if contains(q.CorrectAnswers, choice) {
+				right++
+			} else {
+				wrong++
+			}
+		}
+		return math.Max(0, float64(right-wrong)/float64(len(q.CorrectAnswers)))
+	case QuestionNumeric:
+		if d, ok := q.Distance(a); ok && d == 0 {
+			return 1
+		}
+	case QuestionText:
+		if len(a.Text) > maxTextAnswer {
+			return 0
+		}
+		answer := normaliseText(a.Text)
+		if answer == "" {
+			return 0
+		}
+		for _, accepted := range append([]string{q.CorrectAnswer}, q.Aliases...) {
+			if fuzzyMatch(answer, normaliseText(accepted)) {
+				return 1
+			}
+		}
+	default:
+		if a.Text == q.CorrectAnswer {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Solution returns the right answer to the question, written like Answer.String
+func (q *Question) Solution() string {
+	switch q.Kind() {
+	case QuestionMulti:
+		return Answer{Choices: q.CorrectAnswers}.String()
+	case QuestionNumeric:
+		if q.NumericAnswer != nil {
+			return Answer{Number: q.NumericAnswer}.String()
+		}
+	}
+	return q.CorrectAnswer
+}
+
+// Distance returns how far the answer to a numeric question is from the right number.
+// It returns false if the question is not numeric or the answer has no number.
+func (q *Question) Distance(a Answer) (float64, bool) {
+	if q.Kind() != QuestionNumeric || q.NumericAnswer == nil {
+		return 0, false
+	}
+	number := a.Number
+	if number == nil {
+		n, err := strconv.ParseFloat(strings.TrimSpace(a.Text), 64)
+		if err != nil {
+			return 0, false
+		}
+		number = &n
+	}
+	return math.Abs(*number - *q.NumericAnswer), true
+}
+
+// InTolerance checks if an answer to a numeric question at the distance from the right number can win.
+// It must be within a tenth of the right number, and exact if the number is 0.
+func (q *Question) InTolerance(distance float64) bool {
+	if q.NumericAnswer == nil {
+		return false
+	}
+	return distance <= math.Abs(*q.NumericAnswer)*numericTolerance
+}
+
+// normaliseText lowercases the text, drops punctuation and a leading article, and collapses spaces
+func normaliseText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		if unicode.IsSpace(r) || r == '-' || r == '_' {
+			return ' '
+		}
+		return -1
+	}, text)
+	words := strings.Fields(text)
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// fuzzyMatch accepts a typo for every five letters of the expected answer. Answers of less than five
+// letters must be exact.
+func fuzzyMatch(answer string, expected string) bool {
+	if answer == expected {
+		return true
+	}
+	answerLength, expectedLength := len([]rune(answer)), len([]rune(expected))
+	allowed := expectedLength / 5
+	if allowed == 0 {
+		return false
+	}
+	// every letter of difference in length is an edit
+	if answerLength-expectedLength > allowed || expectedLength-answerLength > allowed {
+		return false
+	}
+	return editDistance(answer, expected) <= allowed
+}
+
+// editDistance returns the number of single letter edits, or swaps of adjacent letters, that turn a into b
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min3(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && rows[i-2][j-2]+1 < rows[i][j] {
+				rows[i][j] = rows[i-2][j-2] + 1
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
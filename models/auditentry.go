@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntry records an action a staff member took on a user account
+type AuditEntry struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	ActorID        primitive.ObjectID `bson:"actorId"`
+	ActorUsername  string             `bson:"actorUsername"`
+	Action         string             `bson:"action"`
+	TargetID       primitive.ObjectID `bson:"targetId"`
+	TargetUsername string             `bson:"targetUsername"`
+	Details        string             `bson:"details"`
+	IP             string             `bson:"ip"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
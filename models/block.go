@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Block hides a user from another one. Blocked users cannot be matched, challenge or befriend each other.
+type Block struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	BlockerID primitive.ObjectID `bson:"blockerId"`
+	BlockedID primitive.ObjectID `bson:"blockedId"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FriendshipStatus is the state of a friendship
+type FriendshipStatus string
+
+const (
+	// FriendshipPending is a friend request waiting for an answer
+	FriendshipPending FriendshipStatus = "pending"
+	// FriendshipAccepted is a friendship both users agreed to
+	FriendshipAccepted FriendshipStatus = "accepted"
+)
+
+// Friendship links two users.
+// Pair identifies the two users regardless of who sent the request.
+type Friendship struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Pair        string             `bson:"pair"`
+	RequesterID primitive.ObjectID `bson:"requesterId"`
+	AddresseeID primitive.ObjectID `bson:"addresseeId"`
+	Status      FriendshipStatus   `bson:"status"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	AcceptedAt  time.Time          `bson:"acceptedAt"`
+}
+
+// Other returns the user of the friendship that is not userID
+func (f *Friendship) Other(userID primitive.ObjectID) primitive.ObjectID {
+	if f.RequesterID == userID {
+		return f.AddresseeID
+	}
+	return f.RequesterID
+}
+
+// FriendshipPair returns the pair of a friendship between two users
+func FriendshipPair(a primitive.ObjectID, b primitive.ObjectID) string {
+	if a.Hex() > b.Hex() {
+		a, b = b, a
+	}
+	return a.Hex() + ":" + b.Hex()
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GameResult is the outcome of a finished game
+type GameResult struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Players    []PlayerResult     `bson:"players"`
+	Winner     string             `bson:"winner"`
+	Forfeit    bool               `bson:"forfeit"`
+	StartedAt  time.Time          `bson:"startedAt"`
+	FinishedAt time.Time          `bson:"finishedAt"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+// PlayerResult is how a player did in a game
+type PlayerResult struct {
+	UserID   primitive.ObjectID `bson:"userId"`
+	Username string             `bson:"username"`
+	Score    float64            `bson:"score"`
+	Won      bool               `bson:"won"`
+	Lost     bool               `bson:"lost"`
+	Answers  []AnswerResult     `bson:"answers"`
+}
+
+// AnswerResult is the answer of a player to a question of a game.
+// ResponseMillis is only meaningful if the question was answered.
+type AnswerResult struct {
+	QuestionID     primitive.ObjectID `bson:"questionId"`
+	Category       string             `bson:"category"`
+	Difficulty     Difficulty         `bson:"difficulty,omitempty"`
+	Answer         string             `bson:"answer"`
+	Answered       bool               `bson:"answered"`
+	Correct        bool               `bson:"correct"`
+	ResponseMillis int64              `bson:"responseMillis"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt tracks the failed login attempts coming from an IP address
+type LoginAttempt struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	IP          string             `bson:"ip"`
+	Failures    int                `bson:"failures"`
+	LastFailure time.Time          `bson:"lastFailure"`
+	LockedUntil time.Time          `bson:"lockedUntil"`
+}
+
+// IsLocked returns true if logins from this IP are currently blocked.
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil.After(now)
+}
@@ -0,0 +1,20 @@
+package models
+
+// MediaType is the kind of media attached to a question
+type MediaType string
+
+const (
+	// MediaImage is shown with the question, e.g. the flag of "name this flag"
+	MediaImage MediaType = "image"
+	// MediaAudio is played with the question
+	MediaAudio MediaType = "audio"
+)
+
+// Media is a file attached to a question. The file is kept in the blob store under Key
+// and is only served at signed URLs.
+type Media struct {
+	Type        MediaType `bson:"type"`
+	Key         string    `bson:"key"`
+	ContentType string    `bson:"contentType"`
+	Size        int64     `bson:"size"`
+}
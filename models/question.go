@@ -1,18 +1,270 @@
 package models
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var (
+	// ErrEmptyQuestion is returned when the text of the question is empty
+	ErrEmptyQuestion = errors.New("question must not be empty")
+	// ErrEmptyAnswer is returned when one of the answers is empty
+	ErrEmptyAnswer = errors.New("answers must not be empty")
+	// ErrDuplicateAnswer is returned when two answers are the same
+	ErrDuplicateAnswer = errors.New("answers must be distinct")
+	// ErrTooFewAnswers is returned when there are less than two answers to choose from
+	ErrTooFewAnswers = errors.New("question must have at least two answers")
+	// ErrCorrectAnswerMissing is returned when the correct answer is not one of the answers
+	ErrCorrectAnswerMissing = errors.New("correct answer must be one of the answers")
+	// ErrInvalidDifficulty is returned when the difficulty is not easy, medium or hard
+	ErrInvalidDifficulty = errors.New("difficulty must be easy, medium or hard")
+	// ErrInvalidQuestionType is returned when the type is not one of the question types
+	ErrInvalidQuestionType = errors.New("type must be single, true_false, multi, numeric or text")
+	// ErrTrueFalseAnswers is returned when a true/false question has other answers than True and False
+	ErrTrueFalseAnswers = errors.New("the answers of a true/false question are True and False")
+	// ErrNoCorrectAnswers is returned when a multi-select question has no correct answer
+	ErrNoCorrectAnswers = errors.New("question must have at least one correct answer")
+	// ErrNumericAnswerMissing is returned when a numeric question has no numeric answer
+	ErrNumericAnswerMissing = errors.New("numeric question must have a numeric answer")
+	// ErrUnexpectedAnswers is returned when a numeric or free text question has answer options
+	ErrUnexpectedAnswers = errors.New("numeric and free text questions have no answer options")
+)
+
+// QuestionType is how a question is answered
+type QuestionType string
+
+const (
+	// QuestionSingle has one correct answer among the answers. Questions without a type are single.
+	QuestionSingle QuestionType = "single"
+	// QuestionTrueFalse is a single choice between True and False
+	QuestionTrueFalse QuestionType = "true_false"
+	// QuestionMulti has several correct answers among the answers, with partial credit
+	QuestionMulti QuestionType = "multi"
+	// QuestionNumeric is answered with a number. The closest answer wins if it is close enough.
+	QuestionNumeric QuestionType = "numeric"
+	// QuestionText is answered with free text, matched loosely against the answer and its aliases
+	QuestionText QuestionType = "text"
+
+	// AnswerTrue and AnswerFalse are the answers of a true/false question
+	AnswerTrue  = "True"
+	AnswerFalse = "False"
+)
+
+// QuestionTypes are the types of questions
+var QuestionTypes = []QuestionType{QuestionSingle, QuestionTrueFalse, QuestionMulti, QuestionNumeric, QuestionText}
+
+// IsValidQuestionType checks if the question type exists
+func IsValidQuestionType(t QuestionType) bool {
+	for _, questionType := range QuestionTypes {
+		if t == questionType {
+			return true
+		}
+	}
+	return false
+}
+
+// Difficulty is how hard a question is
+type Difficulty string
+
+const (
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+// Difficulties are the difficulties from the easiest to the hardest
+var Difficulties = []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard}
+
+// IsValidDifficulty checks if the difficulty exists
+func IsValidDifficulty(d Difficulty) bool {
+	for _, difficulty := range Difficulties {
+		if d == difficulty {
+			return true
+		}
+	}
+	return false
+}
+
+// Multiplier is the factor applied to the score of a correct answer
+func (d Difficulty) Multiplier() float64 {
+	switch d {
+	case DifficultyEasy:
+		return 1
+	case DifficultyHard:
+		return 2
+	}
+	return 1.5
+}
+
 // Question represents a question
 type Question struct {
 	ID            primitive.ObjectID `bson:"_id"`
 	Question      string             `bson:"question"`
 	Answers       []string           `bson:"answers"`
 	CorrectAnswer string             `bson:"correctAnswer"`
-	CreatedAt     time.Time          `bson:"created_at"`
-	UpdatedAt     time.Time          `bson:"updated_at"`
-	Category      Category           `bson:"category"`
+	Type          QuestionType       `bson:"type,omitempty"`
+	// CorrectAnswers are the correct answers of a multi-select question
+	CorrectAnswers []string `bson:"correctAnswers,omitempty"`
+	// NumericAnswer is the answer of a numeric question
+	NumericAnswer *float64 `bson:"numericAnswer,omitempty"`
+	// Aliases are other accepted answers of a free text question
+	Aliases    []string           `bson:"aliases,omitempty"`
+	Difficulty Difficulty         `bson:"difficulty,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at"`
+	CategoryID primitive.ObjectID `bson:"categoryId,omitempty"`
+	// Media is the picture or sound the question is about
+	Media *Media `bson:"media,omitempty"`
+	// Tags are lower case labels used to find questions
+	Tags []string `bson:"tags,omitempty"`
+}
+
+// Level returns the difficulty of the question. Questions without one are medium.
+func (q *Question) Level() Difficulty {
+	if q.Difficulty == "" {
+		return DifficultyMedium
+	}
+	return q.Difficulty
+}
+
+// Kind returns the type of the question. Questions without one are single choice.
+func (q *Question) Kind() QuestionType {
+	if q.Type == "" {
+		return QuestionSingle
+	}
+	return q.Type
+}
+
+// Normalise fills in what a question of its type implies: the answers of a true/false question,
+// and the spelling of its correct answer. Tags are lower cased and deduplicated.
+func (q *Question) Normalise() {
+	q.Tags = NormaliseTags(q.Tags)
+	if q.Kind() != QuestionTrueFalse {
+		return
+	}
+	if len(q.Answers) == 0 {
+		q.Answers = []string{AnswerTrue, AnswerFalse}
+	}
+	for _, answer := range []string{AnswerTrue, AnswerFalse} {
+		if strings.EqualFold(q.CorrectAnswer, answer) {
+			q.CorrectAnswer = answer
+		}
+	}
+}
+
+// NormaliseTags returns the tags trimmed and lower cased, without empty and repeated ones
+func NormaliseTags(tags []string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+	return res
+}
+
+// Validate returns an error if the question cannot be asked.
+// Answers are compared ignoring case and surrounding spaces.
+func (q *Question) Validate() error {
+	if strings.TrimSpace(q.Question) == "" {
+		return ErrEmptyQuestion
+	}
+	if q.Difficulty != "" && !IsValidDifficulty(q.Difficulty) {
+		return ErrInvalidDifficulty
+	}
+	if !IsValidQuestionType(q.Kind()) {
+		return ErrInvalidQuestionType
+	}
+
+	switch q.Kind() {
+	case QuestionNumeric:
+		if len(q.Answers) > 0 {
+			return ErrUnexpectedAnswers
+		}
+		if q.NumericAnswer == nil {
+			return ErrNumericAnswerMissing
+		}
+		return nil
+	case QuestionText:
+		if len(q.Answers) > 0 {
+			return ErrUnexpectedAnswers
+		}
+		if normaliseText(q.CorrectAnswer) == "" {
+			return ErrCorrectAnswerMissing
+		}
+		return nil
+	}
+
+	if err := validateOptions(q.Answers); err != nil {
+		return err
+	}
+	switch q.Kind() {
+	case QuestionTrueFalse:
+		if len(q.Answers) != 2 || !containsFold(q.Answers, AnswerTrue) || !containsFold(q.Answers, AnswerFalse) {
+			return ErrTrueFalseAnswers
+		}
+	case QuestionMulti:
+		if len(q.CorrectAnswers) == 0 {
+			return ErrNoCorrectAnswers
+		}
+		seen := make(map[string]bool)
+		for _, correct := range q.CorrectAnswers {
+			if seen[correct] {
+				return ErrDuplicateAnswer
+			}
+			seen[correct] = true
+			if !contains(q.Answers, correct) {
+				return ErrCorrectAnswerMissing
+			}
+		}
+		return nil
+	}
+	if !contains(q.Answers, q.CorrectAnswer) {
+		return ErrCorrectAnswerMissing
+	}
+	return nil
+}
+
+// validateOptions checks that there are at least two answers to choose from, all different
+func validateOptions(answers []string) error {
+	seen := make(map[string]bool)
+	for _, answer := range answers {
+		key := strings.ToLower(strings.TrimSpace(answer))
+		if key == "" {
+			return ErrEmptyAnswer
+		}
+		if seen[key] {
+			return ErrDuplicateAnswer
+		}
+		seen[key] = true
+	}
+	if len(answers) < 2 {
+		return ErrTooFewAnswers
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
 }
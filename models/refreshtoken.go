@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken represents an issued refresh token.
+// Refresh tokens are rotated on use. Every token issued from the same login shares a Family.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Family    string             `bson:"family"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	TokenHash string             `bson:"tokenHash"`
+	MFA       bool               `bson:"mfa"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at"`
+	RevokedAt *time.Time         `bson:"revoked_at"`
+}
+
+// RevokedToken represents a revoked access token or token family
+type RevokedToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	TokenID   string             `bson:"tokenId"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
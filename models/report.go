@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReportReason is why a player is reported
+type ReportReason string
+
+// ReportStatus is the state of a report in the moderation queue
+type ReportStatus string
+
+const (
+	ReportReasonCheating      ReportReason = "cheating"
+	ReportReasonHarassment    ReportReason = "harassment"
+	ReportReasonOffensiveName ReportReason = "offensive_name"
+	ReportReasonSpam          ReportReason = "spam"
+	ReportReasonOther         ReportReason = "other"
+
+	// ReportOpen is a report waiting for review
+	ReportOpen ReportStatus = "open"
+	// ReportResolved is a report a moderator acted on
+	ReportResolved ReportStatus = "resolved"
+	// ReportDismissed is a report a moderator rejected
+	ReportDismissed ReportStatus = "dismissed"
+)
+
+// ReportReasons are the reasons a player can be reported for
+var ReportReasons = []ReportReason{
+	ReportReasonCheating,
+	ReportReasonHarassment,
+	ReportReasonOffensiveName,
+	ReportReasonSpam,
+	ReportReasonOther,
+}
+
+// Report is a complaint about a player, optionally about a game they played
+type Report struct {
+	ID         primitive.ObjectID  `bson:"_id"`
+	ReporterID primitive.ObjectID  `bson:"reporterId"`
+	ReportedID primitive.ObjectID  `bson:"reportedId"`
+	Reason     ReportReason        `bson:"reason"`
+	Details    string              `bson:"details"`
+	GameID     *primitive.ObjectID `bson:"gameId"`
+	Status     ReportStatus        `bson:"status"`
+	ReviewerID *primitive.ObjectID `bson:"reviewerId"`
+	ReviewNote string              `bson:"reviewNote"`
+	ReviewedAt *time.Time          `bson:"reviewedAt"`
+	CreatedAt  time.Time           `bson:"created_at"`
+}
+
+// IsValidReportReason returns true if the reason is one of ReportReasons
+func IsValidReportReason(reason ReportReason) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
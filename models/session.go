@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session represents a device the user is logged in on.
+// A session lives as long as its refresh token family and shares its id.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Family     string             `bson:"family"`
+	UserID     primitive.ObjectID `bson:"userId"`
+	UserAgent  string             `bson:"userAgent"`
+	IP         string             `bson:"ip"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	LastSeenAt time.Time          `bson:"lastSeenAt"`
+	ExpiresAt  time.Time          `bson:"expires_at"`
+	RevokedAt  *time.Time         `bson:"revoked_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UsedToken records a one-time token that has been redeemed
+type UsedToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	TokenID   string             `bson:"tokenId"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
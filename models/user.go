@@ -3,17 +3,57 @@ package models
 import (
 	"time"
 
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// DeletedUsernamePrefix starts the tombstone name that replaces the username of a deleted user in game history
+const DeletedUsernamePrefix = "deleted-"
+
 // User represents a user
 type User struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	Username    string             `bson:"username"`
-	Password    string             `bson:"password"`
-	CreatedAt   time.Time          `bson:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at"`
-	ProfileURL  string             `bson:"profileURL"`
-	IsAdmin     bool               `bson:"isAdmin"`
-	IsSearching bool               `bson:"isSearching"`
+	ID              primitive.ObjectID `bson:"_id"`
+	Username        string             `bson:"username"`
+	Password        string             `bson:"password"`
+	Email           string             `bson:"email"`
+	EmailVerified   bool               `bson:"emailVerified"`
+	EmailVerifiedAt time.Time          `bson:"emailVerifiedAt"`
+	CreatedAt       time.Time          `bson:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at"`
+	ProfileURL      string             `bson:"profileURL"`
+	DisplayName     string             `bson:"displayName"`
+	Bio             string             `bson:"bio"`
+	Role            rbac.Role          `bson:"role"`
+	IsSearching     bool               `bson:"isSearching"`
+	IsGuest         bool               `bson:"isGuest"`
+	LastSeenAt      time.Time          `bson:"lastSeenAt"`
+	FailedLogins    int                `bson:"failedLogins"`
+	LastFailedLogin time.Time          `bson:"lastFailedLogin"`
+	LockedUntil     time.Time          `bson:"lockedUntil"`
+	Identities      []ExternalIdentity `bson:"identities"`
+	TOTPSecret      string             `bson:"totpSecret"`
+	TOTPEnabled     bool               `bson:"totpEnabled"`
+	TOTPLastStep    int64              `bson:"totpLastStep"`
+	RecoveryCodes   []string           `bson:"recoveryCodes"`
+	Banned          bool               `bson:"banned"`
+	SuspendedUntil  time.Time          `bson:"suspendedUntil"`
+	SuspendReason   string             `bson:"suspendReason"`
+}
+
+// ExternalIdentity links the user to an account at an external identity provider
+type ExternalIdentity struct {
+	Issuer   string    `bson:"issuer"`
+	Subject  string    `bson:"subject"`
+	Email    string    `bson:"email"`
+	LinkedAt time.Time `bson:"linkedAt"`
+}
+
+// IsSuspended returns true if the account is banned or suspended by a moderator.
+func (u *User) IsSuspended(now time.Time) bool {
+	return u.Banned || u.SuspendedUntil.After(now)
+}
+
+// IsLocked returns true if the account is locked out because of failed login attempts.
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil.After(now)
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WsTicket is a single-use ticket that opens a socket for the user of a session
+type WsTicket struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	TicketHash string             `bson:"ticketHash"`
+	UserID     primitive.ObjectID `bson:"userId"`
+	Family     string             `bson:"family"`
+	Origin     string             `bson:"origin"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	ExpiresAt  time.Time          `bson:"expires_at"`
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	auditLogService "github.com/acha-bill/quizzer_backend/packages/dblayer/auditlog"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionSuspend       = "suspend"
+	ActionBan           = "ban"
+	ActionUnban         = "unban"
+	ActionResetPassword = "reset_password"
+	ActionSetRole       = "set_role"
+	ActionLogout        = "logout"
+	ActionClearLockout  = "clear_lockout"
+	ActionReviewReport  = "review_report"
+)
+
+// Record writes what the user holding the context did to the target user.
+// The action has already happened, so a failure to record it is logged rather than returned.
+func Record(ctx echo.Context, action string, target *models.User, format string, args ...interface{}) {
+	claims := common.GetClaims(ctx)
+	actorID, _ := primitive.ObjectIDFromHex(claims.Id)
+	entry := models.AuditEntry{
+		ID:             primitive.NewObjectID(),
+		ActorID:        actorID,
+		ActorUsername:  claims.Username,
+		Action:         action,
+		TargetID:       target.ID,
+		TargetUsername: target.Username,
+		Details:        fmt.Sprintf(format, args...),
+		IP:             ctx.RealIP(),
+		CreatedAt:      time.Now(),
+	}
+	if err := auditLogService.Create(entry); err != nil {
+		log.Errorf("recording %s of %s by %s: %v", action, target.Username, claims.Username, err)
+	}
+}
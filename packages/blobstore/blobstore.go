@@ -0,0 +1,74 @@
+package blobstore
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// PrivatePrefix starts the keys of the blobs that are only served at signed URLs
+	PrivatePrefix = "private/"
+)
+
+var (
+	once     sync.Once
+	instance BlobStore
+
+	// ErrInvalidKey is returned when a key would escape the store
+	ErrInvalidKey = errors.New("invalid blob key")
+	// ErrInvalidSignature is returned when a signed URL is forged or has expired
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+)
+
+// BlobStore stores files and serves them at a URL
+type BlobStore interface {
+	// Put stores the content under the key and returns the URL it is served at
+	Put(key string, r io.Reader, contentType string) (string, error)
+	// Delete removes the blob stored under the key. Deleting a missing blob is not an error.
+	Delete(key string) error
+	// Key returns the key of the blob served at the URL, or false if the store does not serve the URL
+	Key(url string) (string, bool)
+	// SignedURL returns a URL the blob can be read at until the ttl elapses, private or not
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// Instance returns the blob store configured by the BLOB_STORE environment variable.
+// Only "local" is supported for now. It stores blobs in MEDIA_DIR, served at MEDIA_URL.
+// URLs are signed with MEDIA_SIGNING_KEY, or with a random key if it is not set,
+// in which case signed URLs stop working when the server restarts.
+func Instance() BlobStore {
+	once.Do(func() {
+		instance = newBlobStore()
+	})
+	return instance
+}
+
+func newBlobStore() BlobStore {
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "./media"
+	}
+	baseURL := os.Getenv("MEDIA_URL")
+	if baseURL == "" {
+		baseURL = "/media"
+	}
+	signingKey := []byte(os.Getenv("MEDIA_SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		log.Warn("MEDIA_SIGNING_KEY is not set, signed media URLs will not survive a restart")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			log.Fatalf("generating the media signing key: %v", err)
+		}
+	}
+	return &LocalStore{
+		Dir:        dir,
+		BaseURL:    baseURL,
+		SigningKey: signingKey,
+	}
+}
@@ -0,0 +1,150 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore stores blobs on the local filesystem.
+// The echo server serves Dir at BaseURL, see ServeHTTP.
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+	// SigningKey signs the URLs returned by SignedURL
+	SigningKey []byte
+}
+
+// Put writes the content to a file named after the key
+func (s *LocalStore) Put(key string, r io.Reader, _ string) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+
+	// write to a temporary file first so a blob is never served half written
+	tmp := p + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key, nil
+}
+
+// Delete removes the file of the key
+func (s *LocalStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Key returns the key of a URL under BaseURL
+func (s *LocalStore) Key(url string) (string, bool) {
+	prefix := strings.TrimSuffix(s.BaseURL, "/") + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(url, prefix)
+	if _, err := s.path(key); err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// SignedURL returns the URL of the key with its expiry time and a signature of both
+func (s *LocalStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key + "?" + query.Encode(), nil
+}
+
+// ServeHTTP serves the blob at the path of the request, relative to BaseURL.
+// Private blobs are only served at a signed URL that has not expired.
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	p, err := s.path(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasPrefix(key, PrivatePrefix) {
+		query := r.URL.Query()
+		if !s.verify(key, query.Get("expires"), query.Get("signature")) {
+			http.Error(w, ErrInvalidSignature.Error(), http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Cache-Control", "private")
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	// ServeContent answers range requests, which audio players rely on
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (s *LocalStore) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.SigningKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks that the signature is the one of the key and expiry time, and that it has not expired
+func (s *LocalStore) verify(key string, expires string, signature string) bool {
+	t, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > t {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expires)))
+}
+
+// path returns the file of the key. Keys cannot point outside of Dir.
+func (s *LocalStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || clean[1:] != key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
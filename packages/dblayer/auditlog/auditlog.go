@@ -0,0 +1,70 @@
+package auditlog
+
+import (
+	"context"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "audit_log"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// Create creates an audit entry
+func Create(entry models.AuditEntry) error {
+	_, err := collection().InsertOne(ctx, entry)
+	return err
+}
+
+// Find returns the entries matching the filter, most recent first
+func Find(filter interface{}, skip int64, limit int64) ([]*models.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var entries []*models.AuditEntry
+	for cur.Next(ctx) {
+		var e models.AuditEntry
+		if err := cur.Decode(&e); err != nil {
+			return entries, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, cur.Err()
+}
+
+// FindByUser returns the entries where the user is the actor or the target, most recent first
+func FindByUser(userID primitive.ObjectID, skip int64, limit int64) ([]*models.AuditEntry, error) {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "actorId", Value: userID}},
+		bson.D{{Key: "targetId", Value: userID}},
+	}}}
+	return Find(filter, skip, limit)
+}
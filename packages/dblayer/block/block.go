@@ -0,0 +1,108 @@
+package block
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "blocks"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "blockerId", Value: 1}, {Key: "blockedId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "blockedId", Value: 1}}},
+	})
+	return err
+}
+
+// Block blocks the user for the blocker. Blocking twice is not an error.
+func Block(blockerID primitive.ObjectID, blockedID primitive.ObjectID) error {
+	filter := bson.D{
+		{Key: "blockerId", Value: blockerID},
+		{Key: "blockedId", Value: blockedID},
+	}
+	update := bson.D{primitive.E{Key: "$setOnInsert", Value: models.Block{
+		ID:        primitive.NewObjectID(),
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		CreatedAt: time.Now(),
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// Unblock removes the block. It returns false if the user was not blocked.
+func Unblock(blockerID primitive.ObjectID, blockedID primitive.ObjectID) (bool, error) {
+	filter := bson.D{
+		{Key: "blockerId", Value: blockerID},
+		{Key: "blockedId", Value: blockedID},
+	}
+	res, err := collection().DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount == 1, nil
+}
+
+// FindByBlocker returns the blocks of the blocker, most recent first
+func FindByBlocker(blockerID primitive.ObjectID) ([]*models.Block, error) {
+	filter := bson.D{primitive.E{Key: "blockerId", Value: blockerID}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var blocks []*models.Block
+	for cur.Next(ctx) {
+		var b models.Block
+		if err := cur.Decode(&b); err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, &b)
+	}
+	return blocks, cur.Err()
+}
+
+// IsBlocked returns true if either user has blocked the other
+func IsBlocked(a primitive.ObjectID, b primitive.ObjectID) (bool, error) {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "blockerId", Value: a}, {Key: "blockedId", Value: b}},
+		bson.D{{Key: "blockerId", Value: b}, {Key: "blockedId", Value: a}},
+	}}}
+	n, err := collection().CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteByUser deletes the blocks made by or against the user
+func DeleteByUser(userID primitive.ObjectID) error {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "blockerId", Value: userID}},
+		bson.D{{Key: "blockedId", Value: userID}},
+	}}}
+	_, err := collection().DeleteMany(ctx, filter)
+	return err
+}
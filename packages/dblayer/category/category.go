@@ -3,6 +3,7 @@ package category
 import (
 	"context"
 	"errors"
+	"regexp"
 
 	"github.com/acha-bill/quizzer_backend/models"
 	"github.com/acha-bill/quizzer_backend/packages/mongodb"
@@ -18,6 +19,8 @@ const (
 var (
 	ctx                    = context.TODO()
 	ErrNoCategoriesDeleted = errors.New("no categories were deleted")
+	// ErrNoCategoryUpdated is returned when the category to update does not exist
+	ErrNoCategoryUpdated = errors.New("no categories were updated")
 )
 
 func collection() *mongo.Collection {
@@ -47,30 +50,92 @@ func Create(category models.Category) (created *models.Category, err error) {
 	return
 }
 
-func FindById(id string) (category *models.Category, err error) {
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	categories, err := filterCategories(filter)
+// FindById finds the category with the id. It returns nil if there is none.
+func FindById(id string) (*models.Category, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return
+		return nil, nil
 	}
-	if len(categories) == 0 {
-		category = nil
-	} else {
-		category = categories[0]
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
+	var c models.Category
+	err = collection().FindOne(ctx, filter).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
 	}
-	return
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindByName finds the category with the name, ignoring case. It returns nil if there is none.
+func FindByName(name string) (*models.Category, error) {
+	filter := bson.D{primitive.E{Key: "name", Value: primitive.Regex{
+		Pattern: "^" + regexp.QuoteMeta(name) + "$",
+		Options: "i",
+	}}}
+	var c models.Category
+	err := collection().FindOne(ctx, filter).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindByIds finds the categories with the ids
+func FindByIds(ids []primitive.ObjectID) ([]*models.Category, error) {
+	filter := bson.D{primitive.E{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	return filterCategories(filter)
+}
+
+// Names returns the name of every category by id
+func Names() (map[primitive.ObjectID]string, error) {
+	categories, err := FindAll()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[primitive.ObjectID]string)
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+	return names, nil
 }
 
+// UpdateById replaces the fields of the category with the id.
+// It returns ErrNoCategoryUpdated if there is no such category.
 func UpdateById(id string, category models.Category) error {
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	b, _ := bson.Marshal(&category)
-	update := bson.D{primitive.E{Key: "$set", Value: b}}
-	updated := &models.Category{}
-	return collection().FindOneAndUpdate(ctx, filter, update).Decode(updated)
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNoCategoryUpdated
+	}
+	category.ID = objectID
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
+	b, err := bson.Marshal(&category)
+	if err != nil {
+		return err
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.Raw(b)}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNoCategoryUpdated
+	}
+	return nil
 }
 
+// DeleteById deletes the category with the id.
+// It returns ErrNoCategoriesDeleted if there is no such category.
 func DeleteById(id string) error {
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNoCategoriesDeleted
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
 
 	res, err := collection().DeleteOne(ctx, filter)
 	if err != nil {
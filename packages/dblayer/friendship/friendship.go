@@ -0,0 +1,135 @@
+package friendship
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "friendships"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection.
+// Two users can only have one friendship.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "pair", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "requesterId", Value: 1}}},
+		{Keys: bson.D{{Key: "addresseeId", Value: 1}}},
+	})
+	return err
+}
+
+// Create creates a friendship and returns the created friendship
+func Create(friendship models.Friendship) (created *models.Friendship, err error) {
+	res, err := collection().InsertOne(ctx, friendship)
+	if err != nil {
+		return nil, err
+	}
+	friendship.ID = res.InsertedID.(primitive.ObjectID)
+	created = &friendship
+	return
+}
+
+// FindBetween finds the friendship between the two users. It returns nil if there is none.
+func FindBetween(a primitive.ObjectID, b primitive.ObjectID) (*models.Friendship, error) {
+	filter := bson.D{primitive.E{Key: "pair", Value: models.FriendshipPair(a, b)}}
+	var f models.Friendship
+	err := collection().FindOne(ctx, filter).Decode(&f)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// FindByUser returns the friendships and friend requests of the user
+func FindByUser(userID primitive.ObjectID) ([]*models.Friendship, error) {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "requesterId", Value: userID}},
+		bson.D{{Key: "addresseeId", Value: userID}},
+	}}}
+	cur, err := collection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var friendships []*models.Friendship
+	for cur.Next(ctx) {
+		var f models.Friendship
+		if err := cur.Decode(&f); err != nil {
+			return friendships, err
+		}
+		friendships = append(friendships, &f)
+	}
+	return friendships, cur.Err()
+}
+
+// FriendIDs returns the ids of the accepted friends of the user
+func FriendIDs(userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	friendships, err := FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []primitive.ObjectID
+	for _, f := range friendships {
+		if f.Status == models.FriendshipAccepted {
+			ids = append(ids, f.Other(userID))
+		}
+	}
+	return ids, nil
+}
+
+// Accept accepts the pending friendship.
+// It returns false if the friendship is not pending anymore.
+func Accept(id primitive.ObjectID) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: models.FriendshipPending},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "status", Value: models.FriendshipAccepted},
+		{Key: "acceptedAt", Value: time.Now()},
+	}}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// DeleteByID deletes the friendship
+func DeleteByID(id primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	_, err := collection().DeleteOne(ctx, filter)
+	return err
+}
+
+// DeleteByUser deletes the friendships and friend requests of the user
+func DeleteByUser(userID primitive.ObjectID) error {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "requesterId", Value: userID}},
+		bson.D{{Key: "addresseeId", Value: userID}},
+	}}}
+	_, err := collection().DeleteMany(ctx, filter)
+	return err
+}
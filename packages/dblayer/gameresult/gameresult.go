@@ -0,0 +1,186 @@
+package gameresult
+
+import (
+	"context"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "game_results"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+// PlayerStats are the statistics of a player over all their games
+type PlayerStats struct {
+	Played             int64   `bson:"played"`
+	Wins               int64   `bson:"wins"`
+	Losses             int64   `bson:"losses"`
+	Questions          int64   `bson:"questions"`
+	Answered           int64   `bson:"answered"`
+	Correct            int64   `bson:"correct"`
+	MeanResponseMillis float64 `bson:"meanResponseMillis"`
+	FavouriteCategory  string  `bson:"favouriteCategory"`
+}
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "players.userId", Value: 1}}},
+	})
+	return err
+}
+
+// Create creates a game result and returns the created result
+func Create(result models.GameResult) (created *models.GameResult, err error) {
+	res, err := collection().InsertOne(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	result.ID = res.InsertedID.(primitive.ObjectID)
+	created = &result
+	return
+}
+
+// FindById finds the game result with the id. It returns nil if there is none.
+func FindById(id primitive.ObjectID) (*models.GameResult, error) {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	var r models.GameResult
+	err := collection().FindOne(ctx, filter).Decode(&r)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// FindByUser returns the results of the games the user has played, most recent first
+func FindByUser(userID primitive.ObjectID) ([]*models.GameResult, error) {
+	filter := bson.D{primitive.E{Key: "players.userId", Value: userID}}
+	opts := options.Find().SetSort(bson.D{{Key: "finishedAt", Value: -1}})
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var results []*models.GameResult
+	for cur.Next(ctx) {
+		var r models.GameResult
+		if err := cur.Decode(&r); err != nil {
+			return results, err
+		}
+		results = append(results, &r)
+	}
+	return results, cur.Err()
+}
+
+// Anonymise replaces the username of the user with the tombstone name in the games they have played.
+// The results themselves are kept so that the statistics of the other players stay the same.
+func Anonymise(userID primitive.ObjectID, username string, tombstone string) error {
+	winnerFilter := bson.D{
+		{Key: "players.userId", Value: userID},
+		{Key: "winner", Value: username},
+	}
+	winnerUpdate := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "winner", Value: tombstone}}}}
+	if _, err := collection().UpdateMany(ctx, winnerFilter, winnerUpdate); err != nil {
+		return err
+	}
+
+	playerFilter := bson.D{primitive.E{Key: "players.userId", Value: userID}}
+	playerUpdate := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "players.$[player].username", Value: tombstone}}}}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.D{{Key: "player.userId", Value: userID}}},
+	})
+	_, err := collection().UpdateMany(ctx, playerFilter, playerUpdate, opts)
+	return err
+}
+
+// Stats aggregates the results of the games the user has played
+func Stats(userID primitive.ObjectID) (*PlayerStats, error) {
+	player := bson.D{{Key: "players.userId", Value: userID}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: player}},
+		{{Key: "$unwind", Value: "$players"}},
+		{{Key: "$match", Value: player}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "games", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "played", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "wins", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{"$players.won", 1, 0}}}}}},
+					{Key: "losses", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{"$players.lost", 1, 0}}}}}},
+				}}},
+			}},
+			{Key: "answers", Value: bson.A{
+				bson.D{{Key: "$unwind", Value: "$players.answers"}},
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "questions", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "answered", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{"$players.answers.answered", 1, 0}}}}}},
+					{Key: "correct", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{"$players.answers.correct", 1, 0}}}}}},
+					{Key: "meanResponseMillis", Value: bson.D{{Key: "$avg", Value: bson.D{{Key: "$cond", Value: bson.A{
+						"$players.answers.answered", "$players.answers.responseMillis", nil,
+					}}}}}},
+				}}},
+			}},
+			{Key: "categories", Value: bson.A{
+				bson.D{{Key: "$unwind", Value: "$players.answers"}},
+				bson.D{{Key: "$match", Value: bson.D{{Key: "players.answers.category", Value: bson.D{{Key: "$ne", Value: ""}}}}}},
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: "$players.answers.category"},
+					{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+				}}},
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+				bson.D{{Key: "$limit", Value: 1}},
+			}},
+		}}},
+	}
+	cur, err := collection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var facets []struct {
+		Games      []PlayerStats `bson:"games"`
+		Answers    []PlayerStats `bson:"answers"`
+		Categories []struct {
+			Name string `bson:"_id"`
+		} `bson:"categories"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+
+	stats := &PlayerStats{}
+	if len(facets) == 0 {
+		return stats, nil
+	}
+	if games := facets[0].Games; len(games) > 0 {
+		stats.Played, stats.Wins, stats.Losses = games[0].Played, games[0].Wins, games[0].Losses
+	}
+	if answers := facets[0].Answers; len(answers) > 0 {
+		stats.Questions, stats.Answered, stats.Correct = answers[0].Questions, answers[0].Answered, answers[0].Correct
+		stats.MeanResponseMillis = answers[0].MeanResponseMillis
+	}
+	if categories := facets[0].Categories; len(categories) > 0 {
+		stats.FavouriteCategory = categories[0].Name
+	}
+	return stats, nil
+}
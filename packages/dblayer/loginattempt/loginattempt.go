@@ -0,0 +1,80 @@
+package loginattempt
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "login_attempts"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// FindByIP returns the failed login record of the IP address or nil if there is none.
+func FindByIP(ip string) (*models.LoginAttempt, error) {
+	filter := bson.D{primitive.E{Key: "ip", Value: ip}}
+	var a models.LoginAttempt
+	err := collection().FindOne(ctx, filter).Decode(&a)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RecordFailure increments the failure counter of the IP address and returns the updated record.
+// The counter starts again from 1 if the previous failure happened before resetBefore.
+// Both steps are atomic, so that concurrent failures are all counted.
+func RecordFailure(ip string, now time.Time, resetBefore time.Time) (*models.LoginAttempt, error) {
+	stale := bson.D{
+		{Key: "ip", Value: ip},
+		{Key: "lastFailure", Value: bson.D{{Key: "$lt", Value: resetBefore}}},
+	}
+	reset := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "failures", Value: 0}}}}
+	if _, err := collection().UpdateOne(ctx, stale, reset); err != nil {
+		return nil, err
+	}
+
+	filter := bson.D{primitive.E{Key: "ip", Value: ip}}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "failures", Value: 1}}},
+		{Key: "$set", Value: bson.D{{Key: "lastFailure", Value: now}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	updated := &models.LoginAttempt{}
+	err := collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(updated)
+	return updated, err
+}
+
+// SetLockedUntil blocks logins from the IP address until the specified time, unless it is blocked for longer.
+func SetLockedUntil(ip string, until time.Time) error {
+	filter := bson.D{primitive.E{Key: "ip", Value: ip}}
+	update := bson.D{primitive.E{Key: "$max", Value: bson.D{{Key: "lockedUntil", Value: until}}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Clear removes the failed login record of the IP address.
+func Clear(ip string) error {
+	filter := bson.D{primitive.E{Key: "ip", Value: ip}}
+	_, err := collection().DeleteOne(ctx, filter)
+	return err
+}
@@ -0,0 +1,58 @@
+package oidcstate
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "oidc_states"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection.
+// Logins that were never finished are removed by mongodb.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create stores the state of a login that has been started
+func Create(state models.OIDCState) error {
+	_, err := collection().InsertOne(ctx, state)
+	return err
+}
+
+// Consume removes the state and returns it. It returns nil if the state is unknown or has expired.
+func Consume(state string) (*models.OIDCState, error) {
+	filter := bson.D{
+		{Key: "state", Value: state},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+	var s models.OIDCState
+	err := collection().FindOneAndDelete(ctx, filter).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
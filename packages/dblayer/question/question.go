@@ -9,6 +9,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -18,6 +19,10 @@ const (
 var (
 	ctx                  = context.TODO()
 	ErrNoQuestionDeleted = errors.New("no questions were deleted")
+	// ErrNoQuestionUpdated is returned when the question to update does not exist
+	ErrNoQuestionUpdated = errors.New("no questions were updated")
+	// caseInsensitive compares strings ignoring case
+	caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
 )
 
 func collection() *mongo.Collection {
@@ -25,6 +30,16 @@ func collection() *mongo.Collection {
 	return db.Collection(collectionName)
 }
 
+// EnsureIndexes creates the indexes of the collection
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "categoryId", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "question", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)},
+	})
+	return err
+}
+
 func FindAll() (questions []*models.Question, err error) {
 	// passing bson.D{{}} matches all documents in the collection
 	filter := bson.D{{}}
@@ -37,6 +52,12 @@ func Find(filter interface{}) (questions []*models.Question, err error) {
 	return
 }
 
+// FindByTexts returns the questions whose text is one of the texts, ignoring case
+func FindByTexts(texts []string) ([]*models.Question, error) {
+	filter := bson.D{{Key: "question", Value: bson.D{{Key: "$in", Value: texts}}}}
+	return filterQuestions(filter, options.Find().SetCollation(caseInsensitive))
+}
+
 func Create(question models.Question) (created *models.Question, err error) {
 	res, err := collection().InsertOne(ctx, question)
 	if err != nil {
@@ -47,16 +68,82 @@ func Create(question models.Question) (created *models.Question, err error) {
 	return
 }
 
-func UpdateById(id string, question models.Question) {
+// CreateMany inserts the questions in one batch
+func CreateMany(questions []models.Question) error {
+	if len(questions) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(questions))
+	for i := range questions {
+		docs[i] = questions[i]
+	}
+	_, err := collection().InsertMany(ctx, docs)
+	return err
+}
+
+// FindById finds the question with the id. It returns nil if there is none.
+func FindById(id string) (*models.Question, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
+	var q models.Question
+	err = collection().FindOne(ctx, filter).Decode(&q)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// UpdateById replaces the question with the id, so that the fields left empty are removed.
+// It returns ErrNoQuestionUpdated if there is no such question.
+func UpdateById(id string, question models.Question) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNoQuestionUpdated
+	}
+	question.ID = objectID
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
+	res, err := collection().ReplaceOne(ctx, filter, question)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNoQuestionUpdated
+	}
+	return nil
+}
+
+// SetMedia attaches the media to the question with the id, or detaches its media if media is nil.
+// It returns ErrNoQuestionUpdated if there is no such question.
+func SetMedia(id primitive.ObjectID, media *models.Media) error {
 	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	b, _ := bson.Marshal(&question)
-	update := bson.D{primitive.E{Key: "$set", Value: b}}
-	updated := &models.Question{}
-	_ = collection().FindOneAndUpdate(ctx, filter, update).Decode(updated)
+	update := bson.D{{Key: "$unset", Value: bson.D{{Key: "media", Value: ""}}}}
+	if media != nil {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "media", Value: media}}}}
+	}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNoQuestionUpdated
+	}
+	return nil
 }
 
+// DeleteById deletes the question with the id.
+// It returns ErrNoQuestionDeleted if there is no such question.
 func DeleteById(id string) error {
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNoQuestionDeleted
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
 
 	res, err := collection().DeleteOne(ctx, filter)
 	if err != nil {
@@ -70,10 +157,96 @@ func DeleteById(id string) error {
 	return nil
 }
 
-func filterQuestions(filter interface{}) ([]*models.Question, error) {
+// Sample returns n random questions matching the filter.
+// It returns fewer questions if there are not enough.
+func Sample(filter interface{}, n int) ([]*models.Question, error) {
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: filter}},
+		bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: n}}}},
+	}
+	cur, err := collection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
 	var questions []*models.Question
+	if err := cur.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
 
+// DifficultyFilter matches the questions of the difficulty. Questions without one are medium.
+func DifficultyFilter(difficulty models.Difficulty) bson.E {
+	if difficulty == models.DifficultyMedium {
+		return bson.E{Key: "difficulty", Value: bson.D{{Key: "$in", Value: bson.A{difficulty, nil}}}}
+	}
+	return bson.E{Key: "difficulty", Value: difficulty}
+}
+
+// Count counts the questions matching the filter
+func Count(filter interface{}) (int64, error) {
+	return collection().CountDocuments(ctx, filter)
+}
+
+// MigrateCategories replaces the category embedded in the questions created before categories were referenced
+// by the id of that category. Embedded categories without an id are dropped.
+func MigrateCategories() error {
+	filter := bson.D{{Key: "category", Value: bson.D{{Key: "$exists", Value: true}}}}
 	cur, err := collection().Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var q struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Category struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"category"`
+		}
+		if err := cur.Decode(&q); err != nil {
+			return err
+		}
+		update := bson.D{{Key: "$unset", Value: bson.D{{Key: "category", Value: ""}}}}
+		if !q.Category.ID.IsZero() {
+			update = append(update, bson.E{Key: "$set", Value: bson.D{{Key: "categoryId", Value: q.Category.ID}}})
+		}
+		if _, err := collection().UpdateOne(ctx, bson.D{{Key: "_id", Value: q.ID}}, update); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// Each calls fn with the questions matching the filter, oldest first, reading them one at a time from the cursor.
+// It stops at the first error returned by fn.
+func Each(filter interface{}, fn func(*models.Question) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var q models.Question
+		if err := cur.Decode(&q); err != nil {
+			return err
+		}
+		if err := fn(&q); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func filterQuestions(filter interface{}, opts ...*options.FindOptions) ([]*models.Question, error) {
+	var questions []*models.Question
+
+	cur, err := collection().Find(ctx, filter, opts...)
 	if err != nil {
 		return questions, err
 	}
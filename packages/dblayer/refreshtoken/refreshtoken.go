@@ -0,0 +1,114 @@
+package refreshtoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "refresh_tokens"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection.
+// Expired tokens are removed by mongodb.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create creates a refresh token and returns the created token
+func Create(token models.RefreshToken) (created *models.RefreshToken, err error) {
+	res, err := collection().InsertOne(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	token.ID = res.InsertedID.(primitive.ObjectID)
+	created = &token
+	return
+}
+
+// FindByHash finds the refresh token with the hash. It returns nil if there is none.
+func FindByHash(hash string) (*models.RefreshToken, error) {
+	filter := bson.D{primitive.E{Key: "tokenHash", Value: hash}}
+	var t models.RefreshToken
+	err := collection().FindOne(ctx, filter).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// MarkUsed marks the token as used.
+// It returns false if the token had already been used.
+func MarkUsed(id primitive.ObjectID, at time.Time) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "used_at", Value: nil},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "used_at", Value: at}}}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// ActiveFamilies returns the families of the user that have not been revoked
+func ActiveFamilies(userID primitive.ObjectID) ([]string, error) {
+	filter := bson.D{
+		{Key: "userId", Value: userID},
+		{Key: "revoked_at", Value: nil},
+	}
+	values, err := collection().Distinct(ctx, "family", filter)
+	if err != nil {
+		return nil, err
+	}
+	var families []string
+	for _, v := range values {
+		if family, ok := v.(string); ok {
+			families = append(families, family)
+		}
+	}
+	return families, nil
+}
+
+// RevokeFamily revokes every token of the family
+func RevokeFamily(family string, at time.Time) error {
+	filter := bson.D{
+		{Key: "family", Value: family},
+		{Key: "revoked_at", Value: nil},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: at}}}}
+	_, err := collection().UpdateMany(ctx, filter, update)
+	return err
+}
+
+// DeleteByUser deletes every token of the user
+func DeleteByUser(userID primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "userId", Value: userID}}
+	_, err := collection().DeleteMany(ctx, filter)
+	return err
+}
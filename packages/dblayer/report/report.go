@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "reports"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "reportedId", Value: 1}}},
+		{Keys: bson.D{{Key: "reporterId", Value: 1}}},
+	})
+	return err
+}
+
+// Create creates a report and returns the created report
+func Create(report models.Report) (created *models.Report, err error) {
+	res, err := collection().InsertOne(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	report.ID = res.InsertedID.(primitive.ObjectID)
+	created = &report
+	return
+}
+
+// FindById finds the report with the id. It returns nil if there is none.
+func FindById(id primitive.ObjectID) (*models.Report, error) {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	var r models.Report
+	err := collection().FindOne(ctx, filter).Decode(&r)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// FindByStatus returns the reports with the status, oldest first.
+// An empty status returns every report.
+func FindByStatus(status models.ReportStatus, skip int64, limit int64) ([]*models.Report, error) {
+	filter := bson.D{}
+	if status != "" {
+		filter = bson.D{primitive.E{Key: "status", Value: status}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetSkip(skip).SetLimit(limit)
+	return findReports(filter, opts)
+}
+
+// Review closes the report with the status
+func Review(id primitive.ObjectID, status models.ReportStatus, reviewerID primitive.ObjectID, note string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "reviewerId", Value: reviewerID},
+		{Key: "reviewNote", Value: note},
+		{Key: "reviewedAt", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// FindByReporter returns the reports filed by the user, most recent first
+func FindByReporter(reporterID primitive.ObjectID) ([]*models.Report, error) {
+	filter := bson.D{primitive.E{Key: "reporterId", Value: reporterID}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findReports(filter, opts)
+}
+
+// FindByReported returns the reports filed against the user, most recent first
+func FindByReported(reportedID primitive.ObjectID) ([]*models.Report, error) {
+	filter := bson.D{primitive.E{Key: "reportedId", Value: reportedID}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findReports(filter, opts)
+}
+
+func findReports(filter interface{}, opts *options.FindOptions) ([]*models.Report, error) {
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var reports []*models.Report
+	for cur.Next(ctx) {
+		var r models.Report
+		if err := cur.Decode(&r); err != nil {
+			return reports, err
+		}
+		reports = append(reports, &r)
+	}
+	return reports, cur.Err()
+}
@@ -0,0 +1,58 @@
+package revokedtoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "revoked_tokens"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection.
+// Entries are removed by mongodb once the token they revoke has expired.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenId", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Revoke revokes the token id until it expires
+func Revoke(tokenID string, expiresAt time.Time) error {
+	t := models.RevokedToken{
+		ID:        primitive.NewObjectID(),
+		TokenID:   tokenID,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	_, err := collection().InsertOne(ctx, t)
+	return err
+}
+
+// IsRevoked returns true if any of the token ids has been revoked
+func IsRevoked(tokenIDs ...string) (bool, error) {
+	filter := bson.D{primitive.E{Key: "tokenId", Value: bson.D{{Key: "$in", Value: tokenIDs}}}}
+	n, err := collection().CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,166 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "sessions"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection.
+// Expired sessions are removed by mongodb.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "family", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create creates a session and returns the created session
+func Create(session models.Session) (created *models.Session, err error) {
+	res, err := collection().InsertOne(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	session.ID = res.InsertedID.(primitive.ObjectID)
+	created = &session
+	return
+}
+
+// FindById finds the session with the id. It returns nil if there is none.
+func FindById(id primitive.ObjectID) (*models.Session, error) {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	var s models.Session
+	err := collection().FindOne(ctx, filter).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindByFamily finds the session of the token family. It returns nil if there is none.
+func FindByFamily(family string) (*models.Session, error) {
+	filter := bson.D{primitive.E{Key: "family", Value: family}}
+	var s models.Session
+	err := collection().FindOne(ctx, filter).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindActiveByUser returns the sessions of the user that have not been revoked, most recently used first
+func FindActiveByUser(userID primitive.ObjectID) ([]*models.Session, error) {
+	filter := bson.D{
+		{Key: "userId", Value: userID},
+		{Key: "revoked_at", Value: nil},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var sessions []*models.Session
+	for cur.Next(ctx) {
+		var s models.Session
+		if err := cur.Decode(&s); err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, cur.Err()
+}
+
+// Refreshed records that the session has refreshed its tokens from the client
+func Refreshed(family string, userAgent string, ip string, expiresAt time.Time) error {
+	filter := bson.D{primitive.E{Key: "family", Value: family}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "userAgent", Value: userAgent},
+		{Key: "ip", Value: ip},
+		{Key: "lastSeenAt", Value: time.Now()},
+		{Key: "expires_at", Value: expiresAt},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Touch records that the session has just been used.
+// The write is skipped if the session was seen less than interval ago.
+func Touch(family string, interval time.Duration) error {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "family", Value: family},
+		{Key: "lastSeenAt", Value: bson.D{{Key: "$lt", Value: now.Add(-interval)}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "lastSeenAt", Value: now}}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Revoke marks the session of the family as revoked
+func Revoke(family string, at time.Time) error {
+	filter := bson.D{
+		{Key: "family", Value: family},
+		{Key: "revoked_at", Value: nil},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: at}}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// FindByUser returns every session of the user, most recently used first
+func FindByUser(userID primitive.ObjectID) ([]*models.Session, error) {
+	filter := bson.D{primitive.E{Key: "userId", Value: userID}}
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var sessions []*models.Session
+	for cur.Next(ctx) {
+		var s models.Session
+		if err := cur.Decode(&s); err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, cur.Err()
+}
+
+// DeleteByUser deletes every session of the user
+func DeleteByUser(userID primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "userId", Value: userID}}
+	_, err := collection().DeleteMany(ctx, filter)
+	return err
+}
@@ -0,0 +1,66 @@
+package usedtoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "used_tokens"
+)
+
+var (
+	ctx = context.TODO()
+)
+
+func collection() *mongo.Collection {
+	db, _ := mongodb.Database()
+	return db.Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes of the collection.
+// Entries are removed by mongodb once the token they record has expired.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// MarkUsed records the token id as used.
+// It returns false if the token id had already been used.
+func MarkUsed(tokenID string, expiresAt time.Time) (bool, error) {
+	t := models.UsedToken{
+		ID:        primitive.NewObjectID(),
+		TokenID:   tokenID,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	_, err := collection().InsertOne(ctx, t)
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func isDuplicateKey(err error) bool {
+	if e, ok := err.(mongo.WriteException); ok {
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -3,12 +3,17 @@ package user
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/acha-bill/quizzer_backend/models"
 	"github.com/acha-bill/quizzer_backend/packages/mongodb"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -19,6 +24,10 @@ var (
 	ctx = context.TODO()
 	// ErrNoUserDeleted returns a no users detected string
 	ErrNoUserDeleted = errors.New("no users were deleted")
+	// ErrEmailTaken is returned when another user already has the email
+	ErrEmailTaken = errors.New("email already taken")
+	// ErrUsernameTaken is returned when another user already has the username
+	ErrUsernameTaken = errors.New("username already taken")
 )
 
 func collection() *mongo.Collection {
@@ -26,6 +35,24 @@ func collection() *mongo.Collection {
 	return db.Collection(collectionName)
 }
 
+// EnsureIndexes creates the indexes of the collection.
+// Emails are unique. Users without an email store an empty one, which the index leaves out.
+func EnsureIndexes() error {
+	_, err := collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{
+				{Key: "email", Value: bson.D{{Key: "$gt", Value: ""}}},
+			}),
+		},
+	})
+	return err
+}
+
 // FindAll get all documents in collection
 func FindAll() (users []*models.User, err error) {
 	// passing bson.D{{}} matches all documents in the collection
@@ -36,7 +63,8 @@ func FindAll() (users []*models.User, err error) {
 
 // FindById finds the user by id
 func FindById(id string) *models.User {
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	objectID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
 	var u models.User
 	_ = collection().FindOne(ctx, filter).Decode(&u)
 	return &u
@@ -56,9 +84,52 @@ func Find(filter interface{}) (users []*models.User, err error) {
 	return
 }
 
-// Create creates a user and returns the created user
+// Search returns a page of the users whose username, display name or email contains the query,
+// ordered by username, and the number of users matching.
+// An empty query or role matches every user.
+func Search(query string, role rbac.Role, skip int64, limit int64) ([]*models.User, int64, error) {
+	filter := bson.D{}
+	if query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter = append(filter, primitive.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "username", Value: pattern}},
+			bson.D{{Key: "displayName", Value: pattern}},
+			bson.D{{Key: "email", Value: pattern}},
+		}})
+	}
+	if role != "" {
+		filter = append(filter, primitive.E{Key: "role", Value: role})
+	}
+
+	total, err := collection().CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}}).SetSkip(skip).SetLimit(limit)
+	cur, err := collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(ctx)
+
+	var users []*models.User
+	for cur.Next(ctx) {
+		var u models.User
+		if err := cur.Decode(&u); err != nil {
+			return users, total, err
+		}
+		users = append(users, &u)
+	}
+	return users, total, cur.Err()
+}
+
+// Create creates a user and returns the created user.
+// It returns ErrUsernameTaken or ErrEmailTaken if another user has the username or the email.
 func Create(user models.User) (created *models.User, err error) {
 	res, err := collection().InsertOne(ctx, user)
+	if taken := duplicateKey(err); taken != nil {
+		return nil, taken
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -68,17 +139,396 @@ func Create(user models.User) (created *models.User, err error) {
 }
 
 // UpdateByID updates document based on provided ID
-func UpdateByID(id string, user models.User) {
+func UpdateByID(id string, user models.User) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
+	b, err := bson.Marshal(&user)
+	if err != nil {
+		return err
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.Raw(b)}}
+	updated := &models.User{}
+	return collection().FindOneAndUpdate(ctx, filter, update).Decode(updated)
+}
+
+// RecordFailedLogin increments the failed login counter of the user and returns the updated user.
+// The counter starts again from 1 if the previous failure happened before resetBefore.
+// Both steps are atomic, so that concurrent failures are all counted.
+func RecordFailedLogin(id primitive.ObjectID, now time.Time, resetBefore time.Time) (*models.User, error) {
+	stale := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "lastFailedLogin", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: resetBefore}}}}},
+	}
+	reset := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "failedLogins", Value: 0}}}}
+	if _, err := collection().UpdateOne(ctx, stale, reset); err != nil {
+		return nil, err
+	}
+
 	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	b, _ := bson.Marshal(&user)
-	update := bson.D{primitive.E{Key: "$set", Value: b}}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "failedLogins", Value: 1}}},
+		{Key: "$set", Value: bson.D{{Key: "lastFailedLogin", Value: now}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	updated := &models.User{}
-	_ = collection().FindOneAndUpdate(ctx, filter, update).Decode(updated)
+	err := collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(updated)
+	return updated, err
+}
+
+// SetLockedUntil locks the user out of logging in until the specified time, unless they are locked out for longer.
+func SetLockedUntil(id primitive.ObjectID, until time.Time) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$max", Value: bson.D{{Key: "lockedUntil", Value: until}}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// ClearFailedLogins resets the failed login counter and lifts any lockout on the user.
+func ClearFailedLogins(id primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "failedLogins", Value: 0},
+		{Key: "lockedUntil", Value: time.Time{}},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// FindByEmail finds the user with the email. It returns nil if there is none.
+func FindByEmail(email string) (*models.User, error) {
+	filter := bson.D{primitive.E{Key: "email", Value: email}}
+	var u models.User
+	err := collection().FindOne(ctx, filter).Decode(&u)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// FindByIdentity finds the user linked to the external identity. It returns nil if there is none.
+func FindByIdentity(issuer, subject string) (*models.User, error) {
+	filter := bson.D{primitive.E{Key: "identities", Value: bson.D{
+		{Key: "$elemMatch", Value: bson.D{
+			{Key: "issuer", Value: issuer},
+			{Key: "subject", Value: subject},
+		}},
+	}}}
+	var u models.User
+	err := collection().FindOne(ctx, filter).Decode(&u)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// AddIdentity links the external identity to the user
+func AddIdentity(id primitive.ObjectID, identity models.ExternalIdentity) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{
+		{Key: "$push", Value: bson.D{{Key: "identities", Value: identity}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+	}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// UsernameExists returns true if a user has the username
+func UsernameExists(username string) (bool, error) {
+	filter := bson.D{primitive.E{Key: "username", Value: username}}
+	n, err := collection().CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+// SetEmail changes the email of the user. The new email is not verified.
+// It returns ErrEmailTaken if another user has the email.
+func SetEmail(id primitive.ObjectID, email string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "email", Value: email},
+		{Key: "emailVerified", Value: false},
+		{Key: "emailVerifiedAt", Value: time.Time{}},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	if taken := duplicateKey(err); taken != nil {
+		return taken
+	}
+	return err
+}
+
+// VerifyEmail marks the email of the user as verified.
+// It returns false if the user has changed their email in the meantime.
+func VerifyEmail(id primitive.ObjectID, email string) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "email", Value: email},
+	}
+	now := time.Now()
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "emailVerified", Value: true},
+		{Key: "emailVerifiedAt", Value: now},
+		{Key: "updated_at", Value: now},
+	}}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if taken := duplicateKey(err); taken != nil {
+		return false, taken
+	}
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// SetPassword changes the password hash of the user and lifts any lockout.
+func SetPassword(id primitive.ObjectID, hashedPassword string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "password", Value: hashedPassword},
+		{Key: "failedLogins", Value: 0},
+		{Key: "lockedUntil", Value: time.Time{}},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Touch records that the user has just been active
+func Touch(id primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "lastSeenAt", Value: time.Now()}}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Claim turns the guest into a regular user with the username and password hash.
+// It returns false if the user is not a guest, and ErrUsernameTaken if another user has the username.
+func Claim(id primitive.ObjectID, username string, hashedPassword string) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "isGuest", Value: true},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "username", Value: username},
+		{Key: "password", Value: hashedPassword},
+		{Key: "isGuest", Value: false},
+		{Key: "role", Value: rbac.DefaultRole},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// DeleteGuestsInactiveSince deletes the guests that have not been active since the time.
+// It returns the number of deleted guests.
+func DeleteGuestsInactiveSince(since time.Time) (int64, error) {
+	filter := bson.D{
+		{Key: "isGuest", Value: true},
+		{Key: "lastSeenAt", Value: bson.D{{Key: "$lt", Value: since}}},
+	}
+	res, err := collection().DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// SetTOTPSecret stores the secret of a two-factor enrolment that has not been confirmed yet
+func SetTOTPSecret(id primitive.ObjectID, secret string) error {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "totpEnabled", Value: bson.D{{Key: "$ne", Value: true}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "totpSecret", Value: secret},
+		{Key: "totpLastStep", Value: 0},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication with the hashes of the recovery codes
+func EnableTOTP(id primitive.ObjectID, step int64, recoveryCodeHashes []string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "totpEnabled", Value: true},
+		{Key: "totpLastStep", Value: step},
+		{Key: "recoveryCodes", Value: recoveryCodeHashes},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// DisableTOTP turns off two-factor authentication and forgets the secret and recovery codes
+func DisableTOTP(id primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "totpEnabled", Value: false},
+		{Key: "totpSecret", Value: ""},
+		{Key: "totpLastStep", Value: 0},
+		{Key: "recoveryCodes", Value: []string{}},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// UseTOTPStep records the time step of a code that has just been used.
+// It returns false if a code of this step or a later one has already been used.
+func UseTOTPStep(id primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "totpLastStep", Value: bson.D{{Key: "$lt", Value: step}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "totpLastStep", Value: step}}}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// SetRecoveryCodes replaces the hashes of the recovery codes of the user
+func SetRecoveryCodes(id primitive.ObjectID, recoveryCodeHashes []string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "recoveryCodes", Value: recoveryCodeHashes},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// UseRecoveryCode removes the hash of a recovery code from the user.
+// It returns false if the user has no such recovery code.
+func UseRecoveryCode(id primitive.ObjectID, recoveryCodeHash string) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "recoveryCodes", Value: recoveryCodeHash},
+	}
+	update := bson.D{primitive.E{Key: "$pull", Value: bson.D{{Key: "recoveryCodes", Value: recoveryCodeHash}}}}
+	res, err := collection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// SetProfile sets the display name and the bio of the user
+func SetProfile(id primitive.ObjectID, displayName string, bio string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "displayName", Value: displayName},
+		{Key: "bio", Value: bio},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// SetProfileURL sets the avatar of the user
+func SetProfileURL(id primitive.ObjectID, profileURL string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "profileURL", Value: profileURL},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Suspend suspends the user until the time
+func Suspend(id primitive.ObjectID, until time.Time, reason string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "suspendedUntil", Value: until},
+		{Key: "suspendReason", Value: reason},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Ban bans the user for good
+func Ban(id primitive.ObjectID, reason string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "banned", Value: true},
+		{Key: "suspendReason", Value: reason},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Unban lifts the ban or suspension of the user
+func Unban(id primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "banned", Value: false},
+		{Key: "suspendedUntil", Value: time.Time{}},
+		{Key: "suspendReason", Value: ""},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// SetRole changes the role of the user
+func SetRole(id primitive.ObjectID, role rbac.Role) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		{Key: "role", Value: role},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	_, err := collection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// CountByRole returns the number of users holding the role
+func CountByRole(role rbac.Role) (int64, error) {
+	filter := bson.D{primitive.E{Key: "role", Value: role}}
+	return collection().CountDocuments(ctx, filter)
+}
+
+// MigrateRoles gives a role to the users created before roles existed.
+// Users flagged with the legacy isAdmin field become admins, every other user becomes a player.
+func MigrateRoles() error {
+	noRole := bson.E{Key: "role", Value: bson.D{{Key: "$exists", Value: false}}}
+	unsetIsAdmin := bson.E{Key: "$unset", Value: bson.D{{Key: "isAdmin", Value: ""}}}
+
+	adminFilter := bson.D{noRole, {Key: "isAdmin", Value: true}}
+	adminUpdate := bson.D{{Key: "$set", Value: bson.D{{Key: "role", Value: rbac.RoleAdmin}}}, unsetIsAdmin}
+	if _, err := collection().UpdateMany(ctx, adminFilter, adminUpdate); err != nil {
+		return err
+	}
+
+	playerFilter := bson.D{noRole}
+	playerUpdate := bson.D{{Key: "$set", Value: bson.D{{Key: "role", Value: rbac.DefaultRole}}}, unsetIsAdmin}
+	_, err := collection().UpdateMany(ctx, playerFilter, playerUpdate)
+	return err
 }
 
 // DeleteByID deletes a document based on the provided ID
 func DeleteByID(id string) error {
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: objectID}}
 
 	res, err := collection().DeleteOne(ctx, filter)
 	if err != nil {
@@ -123,3 +573,19 @@ func filterUsers(filter interface{}) ([]*models.User, error) {
 
 	return users, nil
 }
+
+// duplicateKey returns ErrUsernameTaken or ErrEmailTaken if the error is a duplicate key in their index, nil otherwise
+func duplicateKey(err error) error {
+	if e, ok := err.(mongo.WriteException); ok {
+		for _, we := range e.WriteErrors {
+			if we.Code != 11000 {
+				continue
+			}
+			if strings.Contains(we.Message, "username_1") {
+				return ErrUsernameTaken
+			}
+			return ErrEmailTaken
+		}
+	}
+	return nil
+}
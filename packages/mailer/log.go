@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"os"
+	"sync"
+
+	"github.com/labstack/gommon/log"
+)
+
+// LogMailer writes emails to a file instead of sending them. It is meant for local development.
+// If Path is empty, emails are written to the log.
+type LogMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+// Send writes the message
+func (m *LogMailer) Send(msg Message) error {
+	if m.Path == "" {
+		log.Infof("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(format(m.From, msg)); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\r\n\r\n")
+	return err
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	once     sync.Once
+	instance Mailer
+)
+
+// Message represents an email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Instance returns the mailer configured by the MAILER environment variable.
+// "smtp" sends real emails, anything else writes them to MAIL_LOG_FILE or to the log.
+func Instance() Mailer {
+	once.Do(func() {
+		instance = newMailer()
+	})
+	return instance
+}
+
+func newMailer() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@quizzer.local"
+	}
+	if os.Getenv("MAILER") == "smtp" {
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	return &LogMailer{
+		Path: os.Getenv("MAIL_LOG_FILE"),
+		From: from,
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send sends the message
+func (m *SMTPMailer) Send(msg Message) error {
+	port := m.Port
+	if port == "" {
+		port = "587"
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, port), auth, m.From, []string{msg.To}, format(m.From, msg))
+}
+
+// headerSanitizer prevents header injection through line breaks
+var headerSanitizer = strings.NewReplacer("\r", "", "\n", "")
+
+// format returns the message in RFC 5322 format
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerSanitizer.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerSanitizer.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerSanitizer.Replace(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+	return []byte(b.String())
+}
@@ -0,0 +1,184 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// keysMaxAge is how long the signing keys of the provider are cached for
+const keysMaxAge = time.Hour
+
+// Claims are the claims of an id token we care about
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type keySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of the id token
+func (p *Provider) verify(raw string, nonce string) (*Claims, error) {
+	mapClaims := jwt.MapClaims{}
+	t, err := jwt.ParseWithClaims(raw, mapClaims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodRSAPSS:
+		default:
+			return nil, ErrInvalidIDToken
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil || !t.Valid {
+		return nil, ErrInvalidIDToken
+	}
+
+	if iss, _ := mapClaims["iss"].(string); iss != p.Issuer && iss != p.Issuer+"/" {
+		return nil, ErrInvalidIDToken
+	}
+	if !hasAudience(mapClaims["aud"], p.ClientID) {
+		return nil, ErrInvalidIDToken
+	}
+	if _, ok := mapClaims["exp"]; !ok {
+		return nil, ErrInvalidIDToken
+	}
+	if n, _ := mapClaims["nonce"].(string); n != nonce {
+		return nil, ErrInvalidIDToken
+	}
+
+	claims := &Claims{Issuer: p.Issuer}
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.PreferredUsername, _ = mapClaims["preferred_username"].(string)
+	claims.Name, _ = mapClaims["name"].(string)
+	switch v := mapClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = v
+	case string:
+		claims.EmailVerified = v == "true"
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+// key returns the signing key of the provider with the kid.
+// The keys are fetched again if the kid is unknown, which is what happens after the provider rotates them.
+func (p *Provider) key(kid string) (interface{}, error) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+	if keys != nil && time.Since(keys.fetchedAt) < keysMaxAge {
+		if k := keys.find(kid); k != nil {
+			return k, nil
+		}
+	}
+
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(d.JWKSURI, &body); err != nil {
+		return nil, err
+	}
+	keys = &keySet{keys: make(map[string]interface{}), fetchedAt: time.Now()}
+	for _, jwk := range body.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if k := jwk.publicKey(); k != nil {
+			keys.keys[jwk.Kid] = k
+		}
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	if k := keys.find(kid); k != nil {
+		return k, nil
+	}
+	return nil, ErrInvalidIDToken
+}
+
+// find returns the key with the kid. Without a kid, the only key of the set is returned.
+func (s *keySet) find(kid string) interface{} {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k
+		}
+	}
+	return s.keys[kid]
+}
+
+func (k jsonWebKey) publicKey() interface{} {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	}
+	return nil
+}
+
+// hasAudience returns true if the aud claim, a string or an array of strings, contains the client id
+func hasAudience(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	once     sync.Once
+	instance *Provider
+
+	// ErrNotConfigured is returned when no identity provider is configured
+	ErrNotConfigured = errors.New("oidc login is not configured")
+	// ErrInvalidIDToken is returned when the id token returned by the provider cannot be verified
+	ErrInvalidIDToken = errors.New("invalid id token")
+)
+
+// Provider is an OpenID Connect identity provider
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	client    *http.Client
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+// discovery is the subset of the provider metadata we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Instance returns the provider configured by the OIDC_* environment variables.
+// It returns nil if OIDC_ISSUER is not set.
+func Instance() *Provider {
+	once.Do(func() {
+		issuer := os.Getenv("OIDC_ISSUER")
+		if issuer == "" {
+			return
+		}
+		scopes := []string{"openid", "profile", "email"}
+		if s := os.Getenv("OIDC_SCOPES"); s != "" {
+			scopes = strings.Fields(s)
+		}
+		instance = NewProvider(issuer, os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), os.Getenv("OIDC_REDIRECT_URL"), scopes)
+	})
+	return instance
+}
+
+// NewProvider returns a provider for the issuer. The provider metadata is discovered on first use.
+func NewProvider(issuer, clientID, clientSecret, redirectURL string, scopes []string) *Provider {
+	return &Provider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the URL of the provider the user must be sent to in order to log in.
+// codeVerifier is the PKCE verifier that must be presented again when exchanging the code.
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the verified claims of the id token.
+func (p *Provider) Exchange(code, codeVerifier, nonce string) (*Claims, error) {
+	d, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, ErrInvalidIDToken
+	}
+	return p.verify(body.IDToken, nonce)
+}
+
+func (p *Provider) getDiscovery() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(d.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected %s got %s", p.Issuer, d.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *Provider) getJSON(u string, v interface{}) error {
+	res, err := p.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// RandomString returns a random url safe string suitable for states, nonces and PKCE verifiers
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of the verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
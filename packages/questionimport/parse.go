@@ -0,0 +1,260 @@
+package questionimport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+)
+
+// Format is the format of an import file
+type Format string
+
+const (
+	// FormatCSV is a CSV file with a header row. The answers column separates the answers with "|".
+	FormatCSV Format = "csv"
+	// FormatJSON is an array of questions in our JSON schema
+	FormatJSON Format = "json"
+	// FormatOpenTDB is a response of the Open Trivia DB API
+	FormatOpenTDB Format = "opentdb"
+
+	answerSeparator = "|"
+)
+
+var (
+	// ErrUnknownFormat is returned when the format is not one of the supported formats
+	ErrUnknownFormat = errors.New("unknown format, expected csv, json or opentdb")
+	// ErrMissingColumn is returned when a column of the CSV header is missing
+	ErrMissingColumn = errors.New("missing column")
+	// ErrShortRow is returned for a CSV row with fewer columns than the header
+	ErrShortRow = errors.New("row has fewer columns than the header")
+	// ErrOpenTDBResponse is returned when the Open Trivia DB response is not successful
+	ErrOpenTDBResponse = errors.New("open trivia db response code is not 0")
+)
+
+// Row is a question read from an import file
+type Row struct {
+	// Line is the position of the question in the file, starting at 1.
+	// For a CSV file it is the line of the file the row starts on.
+	Line          int      `json:"line"`
+	Question      string   `json:"question"`
+	Answers       []string `json:"answers"`
+	CorrectAnswer string   `json:"correctAnswer"`
+	Category      string   `json:"category,omitempty"`
+	Difficulty    string   `json:"difficulty,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	// CorrectAnswers are the correct answers of a multi-select question.
+	// They can also be given as the correct answer separated by "|".
+	CorrectAnswers []string `json:"correctAnswers,omitempty"`
+	// NumericAnswer is the answer of a numeric question. It can also be given as the correct answer.
+	NumericAnswer *float64 `json:"numericAnswer,omitempty"`
+	Aliases       []string `json:"aliases,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+
+	// err is set when the row could not be read, which makes it invalid
+	err error
+}
+
+// IsValidFormat checks if the format is supported
+func IsValidFormat(format Format) bool {
+	return format == FormatCSV || format == FormatJSON || format == FormatOpenTDB
+}
+
+// DetectFormat guesses the format of the file from its name, then from its content.
+// A JSON object is taken as an Open Trivia DB response and a JSON array as our schema.
+func DetectFormat(filename string, data []byte) Format {
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return FormatCSV
+	}
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatOpenTDB
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// Parse reads the questions of the file
+func Parse(format Format, data []byte) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatOpenTDB:
+		return parseOpenTDB(data)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// parseCSV reads a CSV file whose header names the question, answers, correctAnswer, category, difficulty,
+// type, aliases and tags columns. Column names are matched ignoring case, and only the first three are required.
+// Rows with fewer columns than the header are returned as invalid rows.
+func parseCSV(data []byte) ([]Row, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.Replace(name, "_", "", -1)
+		columns[name] = i
+	}
+	for _, name := range []string{"question", "answers", "correctanswer"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	lines := recordLines(data)
+	var rows []Row
+	for i := 1; ; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line := 0
+		if i < len(lines) {
+			line = lines[i]
+		}
+		if len(record) < len(header) {
+			rows = append(rows, Row{
+				Line:     line,
+				Question: field(record, "question"),
+				err:      ErrShortRow,
+			})
+			continue
+		}
+		row := Row{
+			Line:          line,
+			Question:      field(record, "question"),
+			CorrectAnswer: field(record, "correctanswer"),
+			Category:      field(record, "category"),
+			Difficulty:    field(record, "difficulty"),
+			Type:          field(record, "type"),
+		}
+		if answers := field(record, "answers"); answers != "" {
+			row.Answers = strings.Split(answers, answerSeparator)
+		}
+		if aliases := field(record, "aliases"); aliases != "" {
+			row.Aliases = strings.Split(aliases, answerSeparator)
+		}
+		if tags := field(record, "tags"); tags != "" {
+			row.Tags = strings.Split(tags, answerSeparator)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// recordLines returns the line each record of the CSV data starts on. A quoted field may span lines,
+// and empty lines are skipped like the CSV reader does.
+func recordLines(data []byte) []int {
+	var lines []int
+	line, quoted, start := 1, false, true
+	for i, c := range data {
+		if start && c != '\n' && !(c == '\r' && i+1 < len(data) && data[i+1] == '\n') {
+			lines = append(lines, line)
+			start = false
+		}
+		switch c {
+		case '"':
+			quoted = !quoted
+		case '\n':
+			line++
+			if !quoted {
+				start = true
+			}
+		}
+	}
+	return lines
+}
+
+// parseJSON reads an array of questions in our schema
+func parseJSON(data []byte) ([]Row, error) {
+	var rows []Row
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
+
+// openTDBResponse is a response of https://opentdb.com/api.php
+type openTDBResponse struct {
+	ResponseCode int `json:"response_code"`
+	Results      []struct {
+		Category         string   `json:"category"`
+		Type             string   `json:"type"`
+		Difficulty       string   `json:"difficulty"`
+		Question         string   `json:"question"`
+		CorrectAnswer    string   `json:"correct_answer"`
+		IncorrectAnswers []string `json:"incorrect_answers"`
+	} `json:"results"`
+}
+
+// parseOpenTDB reads an Open Trivia DB response. Its text is HTML encoded by default, so entities are decoded.
+// The correct answer is put at a random position among the incorrect ones, except for boolean questions
+// which become true/false questions.
+func parseOpenTDB(data []byte) ([]Row, error) {
+	var res openTDBResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	if res.ResponseCode != 0 {
+		return nil, fmt.Errorf("%w: %d", ErrOpenTDBResponse, res.ResponseCode)
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var rows []Row
+	for i, result := range res.Results {
+		row := Row{
+			Line:          i + 1,
+			Question:      html.UnescapeString(result.Question),
+			CorrectAnswer: html.UnescapeString(result.CorrectAnswer),
+			Category:      html.UnescapeString(result.Category),
+			Difficulty:    result.Difficulty,
+		}
+		if result.Type == "boolean" {
+			row.Type = string(models.QuestionTrueFalse)
+			rows = append(rows, row)
+			continue
+		}
+		for _, answer := range result.IncorrectAnswers {
+			row.Answers = append(row.Answers, html.UnescapeString(answer))
+		}
+		pos := random.Intn(len(row.Answers) + 1)
+		row.Answers = append(row.Answers, "")
+		copy(row.Answers[pos+1:], row.Answers[pos:])
+		row.Answers[pos] = row.CorrectAnswer
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
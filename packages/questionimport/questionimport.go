@@ -0,0 +1,200 @@
+package questionimport
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// StatusImported is the status of a row that was imported, or would be in a dry run
+	StatusImported = "imported"
+	// StatusInvalid is the status of a row that failed validation
+	StatusInvalid = "invalid"
+	// StatusDuplicate is the status of a row whose question is already in the file or the database
+	StatusDuplicate = "duplicate"
+
+	batchSize = 500
+)
+
+// RowResult is the outcome of the import of a row
+type RowResult struct {
+	Line     int    `json:"line"`
+	Question string `json:"question"`
+	Category string `json:"category,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Result is the outcome of an import
+type Result struct {
+	DryRun            bool        `json:"dryRun"`
+	Total             int         `json:"total"`
+	Imported          int         `json:"imported"`
+	Failed            int         `json:"failed"`
+	CategoriesCreated []string    `json:"categoriesCreated"`
+	Rows              []RowResult `json:"rows"`
+}
+
+// Import validates the rows and inserts the valid ones in batches.
+// Categories are matched by name ignoring case, and missing ones are created.
+// A question that is already in the database or earlier in the file is skipped, comparing questions ignoring case.
+// In a dry run nothing is written, but the result is the same as a real import.
+func Import(rows []Row, dryRun bool) (*Result, error) {
+	categories, err := categoryService.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	categoryByName := make(map[string]models.Category)
+	for _, c := range categories {
+		categoryByName[key(c.Name)] = *c
+	}
+	existing, err := existingQuestions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		DryRun:            dryRun,
+		Total:             len(rows),
+		CategoriesCreated: []string{},
+		Rows:              []RowResult{},
+	}
+	now := time.Now()
+	var questions []models.Question
+	for _, row := range rows {
+		q := row.question()
+		rowResult := RowResult{
+			Line:     row.Line,
+			Question: q.Question,
+			Category: strings.TrimSpace(row.Category),
+			Status:   StatusImported,
+		}
+		if row.err != nil {
+			rowResult.Status = StatusInvalid
+			rowResult.Error = row.err.Error()
+		} else if err := q.Validate(); err != nil {
+			rowResult.Status = StatusInvalid
+			rowResult.Error = err.Error()
+		} else if existing[key(q.Question)] {
+			rowResult.Status = StatusDuplicate
+		}
+		if rowResult.Status != StatusImported {
+			res.Failed++
+			res.Rows = append(res.Rows, rowResult)
+			continue
+		}
+		existing[key(q.Question)] = true
+
+		if rowResult.Category != "" {
+			category, ok := categoryByName[key(rowResult.Category)]
+			if !ok {
+				category = models.Category{
+					ID:        primitive.NewObjectID(),
+					Name:      rowResult.Category,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}
+				if !dryRun {
+					if _, err := categoryService.Create(category); err != nil {
+						return nil, err
+					}
+				}
+				categoryByName[key(category.Name)] = category
+				res.CategoriesCreated = append(res.CategoriesCreated, category.Name)
+			}
+			q.CategoryID = category.ID
+			rowResult.Category = category.Name
+		}
+		q.ID = primitive.NewObjectID()
+		q.CreatedAt = now
+		q.UpdatedAt = now
+		questions = append(questions, q)
+		res.Imported++
+		res.Rows = append(res.Rows, rowResult)
+	}
+
+	if dryRun {
+		return res, nil
+	}
+	for start := 0; start < len(questions); start += batchSize {
+		end := start + batchSize
+		if end > len(questions) {
+			end = len(questions)
+		}
+		if err := questionService.CreateMany(questions[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// existingQuestions returns the questions of the rows that are already in the database,
+// compared ignoring case like the questions of the file, see key
+func existingQuestions(rows []Row) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	var texts []string
+	for _, row := range rows {
+		texts = append(texts, strings.TrimSpace(row.Question))
+	}
+	if len(texts) == 0 {
+		return existing, nil
+	}
+	found, err := questionService.FindByTexts(texts)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range found {
+		existing[key(q.Question)] = true
+	}
+	return existing, nil
+}
+
+// question returns the question of the row with surrounding spaces removed.
+// The correct answer stands for the correct answers of a multi-select question and the number of a numeric one
+// when they are not given.
+func (row *Row) question() models.Question {
+	q := models.Question{
+		Question:       strings.TrimSpace(row.Question),
+		Type:           models.QuestionType(strings.ToLower(strings.TrimSpace(row.Type))),
+		Answers:        trimAll(row.Answers),
+		CorrectAnswer:  strings.TrimSpace(row.CorrectAnswer),
+		CorrectAnswers: trimAll(row.CorrectAnswers),
+		NumericAnswer:  row.NumericAnswer,
+		Aliases:        trimAll(row.Aliases),
+		Difficulty:     models.Difficulty(strings.ToLower(strings.TrimSpace(row.Difficulty))),
+		Tags:           row.Tags,
+	}
+	switch q.Kind() {
+	case models.QuestionMulti:
+		if len(q.CorrectAnswers) == 0 && q.CorrectAnswer != "" {
+			q.CorrectAnswers = trimAll(strings.Split(q.CorrectAnswer, answerSeparator))
+			q.CorrectAnswer = ""
+		}
+	case models.QuestionNumeric:
+		if n, err := strconv.ParseFloat(q.CorrectAnswer, 64); q.NumericAnswer == nil && err == nil {
+			q.NumericAnswer = &n
+			q.CorrectAnswer = ""
+		}
+	}
+	q.Normalise()
+	return q
+}
+
+func trimAll(values []string) []string {
+	var res []string
+	for _, v := range values {
+		res = append(res, strings.TrimSpace(v))
+	}
+	return res
+}
+
+// key normalises a name or question for comparison
+func key(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
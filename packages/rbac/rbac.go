@@ -0,0 +1,111 @@
+package rbac
+
+// Role is a named set of permissions held by a user
+type Role string
+
+// Permission represents an action a user can be allowed to perform
+type Permission string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleModerator Role = "moderator"
+	RoleAuthor    Role = "author"
+	RolePlayer    Role = "player"
+	// RoleGuest is held by temporary accounts until they are claimed
+	RoleGuest Role = "guest"
+)
+
+const (
+	// PermissionNone is required by public routes. No token is needed to use them.
+	PermissionNone Permission = ""
+	// PermissionAuthenticated is held by every logged in user whatever their role.
+	PermissionAuthenticated Permission = "authenticated"
+
+	PermissionGamePlay      Permission = "game:play"
+	PermissionQuestionRead  Permission = "question:read"
+	PermissionQuestionWrite Permission = "question:write"
+	PermissionCategoryRead  Permission = "category:read"
+	PermissionCategoryWrite Permission = "category:write"
+	PermissionUserManage    Permission = "user:manage"
+	PermissionRoleManage    Permission = "role:manage"
+)
+
+// DefaultRole is the role given to new users
+const DefaultRole = RolePlayer
+
+var rolePermissions = map[Role][]Permission{
+	RoleGuest: {
+		PermissionGamePlay,
+	},
+	RolePlayer: {
+		PermissionGamePlay,
+	},
+	RoleAuthor: {
+		PermissionGamePlay,
+		PermissionQuestionRead,
+		PermissionQuestionWrite,
+		PermissionCategoryRead,
+	},
+	RoleModerator: {
+		PermissionGamePlay,
+		PermissionQuestionRead,
+		PermissionQuestionWrite,
+		PermissionCategoryRead,
+		PermissionCategoryWrite,
+		PermissionUserManage,
+	},
+	RoleAdmin: {
+		PermissionGamePlay,
+		PermissionQuestionRead,
+		PermissionQuestionWrite,
+		PermissionCategoryRead,
+		PermissionCategoryWrite,
+		PermissionUserManage,
+		PermissionRoleManage,
+	},
+}
+
+// Roles returns all the roles ordered from the least to the most privileged
+func Roles() []Role {
+	return []Role{RoleGuest, RolePlayer, RoleAuthor, RoleModerator, RoleAdmin}
+}
+
+// IsValid returns true if the role exists
+func IsValid(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permissions returns the permissions granted to the role
+func Permissions(role Role) []Permission {
+	return rolePermissions[role]
+}
+
+// Can returns true if the role is granted the permission
+func Can(role Role, permission Permission) bool {
+	if permission == PermissionNone {
+		return true
+	}
+	if !IsValid(role) {
+		return false
+	}
+	if permission == PermissionAuthenticated {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivileged returns true if the role grants permissions a player does not have
+func IsPrivileged(role Role) bool {
+	for _, p := range rolePermissions[role] {
+		if !Can(RolePlayer, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,24 +4,32 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 
 	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/packages/blobstore"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/token"
 	"github.com/acha-bill/quizzer_backend/plugins"
+	"github.com/acha-bill/quizzer_backend/plugins/admin"
 	"github.com/acha-bill/quizzer_backend/plugins/auth"
+	"github.com/acha-bill/quizzer_backend/plugins/category"
+	"github.com/acha-bill/quizzer_backend/plugins/challenge"
+	"github.com/acha-bill/quizzer_backend/plugins/friend"
+	"github.com/acha-bill/quizzer_backend/plugins/moderation"
 	"github.com/acha-bill/quizzer_backend/plugins/question"
 	"github.com/acha-bill/quizzer_backend/plugins/search"
+	"github.com/acha-bill/quizzer_backend/plugins/user"
 	"github.com/gobuffalo/packr/v2"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
 )
 
 var (
-	once      sync.Once
-	server    *echo.Echo
-	jwtSecret string
+	once   sync.Once
+	server *echo.Echo
 )
 
 var (
@@ -31,9 +39,15 @@ var (
 
 var (
 	Plugins = []plugins.Plugin{
+		admin.Plugin(),
 		auth.Plugin(),
+		category.Plugin(),
+		challenge.Plugin(),
+		friend.Plugin(),
+		moderation.Plugin(),
 		question.Plugin(),
 		search.Plugin(),
+		user.Plugin(),
 	}
 )
 
@@ -53,7 +67,6 @@ func Instance() *echo.Echo {
 func instance() *echo.Echo {
 	// Echo instance
 	e := echo.New()
-	jwtSecret = os.Getenv("JWT_SECRET")
 
 	// Middleware
 	e.Use(middleware.Logger())
@@ -80,17 +93,24 @@ func instance() *echo.Echo {
 	// index route
 	e.GET("/", indexRoute)
 
+	// public keys of the access tokens
+	e.GET("/.well-known/jwks.json", jwks)
+
+	// uploaded files
+	if store, ok := blobstore.Instance().(*blobstore.LocalStore); ok {
+		prefix := strings.TrimSuffix(store.BaseURL, "/")
+		e.GET(prefix+"/*", echo.WrapHandler(http.StripPrefix(prefix, store)))
+	}
+
 	// Plugin Routes
 	for _, plugin := range Plugins {
 		for _, handler := range plugin.Handlers() {
+			handler := handler
 			path := "api/" + ApiVersion + "/" + plugin.Name() + handler.Path
-			e.Add(handler.Method, path, handler.Handler, middleware.JWTWithConfig(middleware.JWTConfig{
-				Skipper: func(ctx echo.Context) bool {
-					return handler.AuthLevel == plugins.AuthLevelNone
-				},
-				Claims:     &common.JWTCustomClaims{},
-				SigningKey: []byte(jwtSecret),
-			}))
+			skipper := func(ctx echo.Context) bool {
+				return handler.Permission == rbac.PermissionNone
+			}
+			e.Add(handler.Method, path, handler.Handler, authenticate(skipper), permissionCheck(handler.Permission))
 		}
 	}
 
@@ -99,6 +119,59 @@ func instance() *echo.Echo {
 	return e
 }
 
+// authenticate verifies the bearer access token of the request with the keyring
+// and rejects tokens that have been revoked.
+func authenticate(skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if skipper(ctx) {
+				return next(ctx)
+			}
+			auth := ctx.Request().Header.Get(echo.HeaderAuthorization)
+			if !strings.HasPrefix(auth, "Bearer ") {
+				return middleware.ErrJWTMissing
+			}
+			claims, err := token.Parse(strings.TrimPrefix(auth, "Bearer "))
+			if err == token.ErrInvalidToken || err == token.ErrTokenRevoked {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if err != nil {
+				return err
+			}
+			ctx.Set("user", claims)
+			if err := token.Touch(claims.Family); err != nil {
+				log.Errorf("%v", err)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// permissionCheck rejects requests from users whose role does not grant the permission
+func permissionCheck(permission rbac.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if permission == rbac.PermissionNone {
+				return next(ctx)
+			}
+			if !common.Can(ctx, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "missing permission "+string(permission))
+			}
+			// privileges beyond those of a player need a second factor when the setting is on
+			claims := common.GetClaims(ctx)
+			if common.RequireAdmin2FA() && !claims.MFA && !rbac.Can(rbac.RolePlayer, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "two-factor authentication required")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// jwks serves the public keys that verify our access tokens
+func jwks(e echo.Context) error {
+	return e.JSON(http.StatusOK, token.PublicKeys())
+}
+
 func fromFile(e echo.Context) error {
 	reqPath := e.Request().URL.Path
 	bytes, err := appBox.Find(reqPath)
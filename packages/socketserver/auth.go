@@ -2,40 +2,109 @@ package socketserver
 
 import (
 	"errors"
+	"net/http"
+	"strings"
+	"time"
 
-	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
 	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
-	"github.com/dgrijalva/jwt-go"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// Subprotocol is the websocket subprotocol selected by the server.
+	// Browsers offer it together with the bearer protocol to authenticate.
+	Subprotocol = "quizzer"
+	// bearerProtocolPrefix prefixes the access token offered as a subprotocol
+	bearerProtocolPrefix = "bearer."
 )
 
 var (
 	ErrSocketNotAuthenticated = errors.New("socket not authorized")
+	// ErrSocketUserMismatch is returned when an auth message carries the token of another user
+	ErrSocketUserMismatch = errors.New("token belongs to another user")
 )
 
+// authenticate identifies the user of the upgrade request and returns the token family of their session.
+// The request carries a ticket from /auth/ws-ticket in the "ticket" query parameter,
+// or an access token in the Authorization header or in a "bearer.<token>" subprotocol.
+func authenticate(r *http.Request) (*models.User, string, error) {
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		t, err := token.RedeemTicket(ticket, r.Header.Get("Origin"))
+		if err != nil {
+			return nil, "", err
+		}
+		return findSocketUser(t.UserID.Hex(), t.Family)
+	}
+
+	signed := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		signed = strings.TrimPrefix(auth, "Bearer ")
+	} else {
+		for _, protocol := range subprotocols(r) {
+			if strings.HasPrefix(protocol, bearerProtocolPrefix) {
+				signed = strings.TrimPrefix(protocol, bearerProtocolPrefix)
+				break
+			}
+		}
+	}
+	if signed == "" {
+		return nil, "", ErrSocketNotAuthenticated
+	}
+
+	claims, err := token.Parse(signed)
+	if err != nil {
+		return nil, "", err
+	}
+	return findSocketUser(claims.Id, claims.Family)
+}
+
+func findSocketUser(userID string, family string) (*models.User, string, error) {
+	user := userService.FindById(userID)
+	if user.ID.IsZero() || user.IsSuspended(time.Now()) {
+		return nil, "", ErrSocketNotAuthenticated
+	}
+	if err := token.Touch(family); err != nil {
+		log.Errorf("%v", err)
+	}
+	return user, family, nil
+}
+
+func subprotocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header["Sec-Websocket-Protocol"] {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	return protocols
+}
+
+// handleAuthMessage checks a token sent over an authenticated socket.
+// Sockets are authenticated when they connect; the message is kept for older clients
+// and moves the socket to the session of the token.
 func handleAuthMessage(wsConnection *WsConnection, msg SocketMessageAuth) {
-	token, err := jwt.Parse(msg.Token, func(token *jwt.Token) (interface{}, error) {
-		return token, nil
-	})
-	if token == nil || err != nil {
-		ServerManager().WriteConnection(wsConnection, NewSocketResponseAuth("err parsing jwt"))
+	claims, err := token.Parse(msg.Token)
+	if err != nil {
+		ServerManager().WriteConnection(wsConnection, NewSocketResponseAuth(err.Error()))
 		return
 	}
-	if !token.Valid {
-		ServerManager().WriteConnection(wsConnection, NewSocketResponseAuth("Invalid jwt"))
+	if claims.Id != wsConnection.Context.User.ID.Hex() {
+		ServerManager().WriteConnection(wsConnection, NewSocketResponseAuth(ErrSocketUserMismatch.Error()))
 		return
 	}
-	claims := token.Claims.(common.JWTCustomClaims)
-	userId := claims.Id
-	user := userService.FindById(userId)
-	wsConnection.Context.User = user
-	wsConnection.Context.Ready = true
+	ServerManager().SetFamily(wsConnection, claims.Family)
+	if err := token.Touch(claims.Family); err != nil {
+		log.Errorf("%v", err)
+	}
 
 	ServerManager().WriteConnection(wsConnection, NewSocketResponseAuth(""))
 }
 
 // SocketMessageAuth is the auth message
 type SocketMessageAuth struct {
-	Token string
+	Token string `json:"token"`
 }
 
 const authResponseType = "auth"
@@ -0,0 +1,257 @@
+package socketserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// ChallengeTTL is how long a challenge can be accepted for
+	ChallengeTTL = 30 * time.Second
+
+	responseChallengeType          = "challenge"
+	responseChallengeSentType      = "challengeSent"
+	responseChallengeDeclinedType  = "challengeDeclined"
+	responseChallengeCancelledType = "challengeCancelled"
+	responseChallengeExpiredType   = "challengeExpired"
+)
+
+var (
+	// ErrUserNotConnected is returned when the challenged user has no socket
+	ErrUserNotConnected = errors.New("user not connected")
+	// ErrChallengeSelf is returned when a user challenges themselves
+	ErrChallengeSelf = errors.New("you cannot challenge yourself")
+	// ErrChallengeExists is returned when a challenge between the two users is already pending
+	ErrChallengeExists = errors.New("a challenge is already pending")
+	// ErrUserBlocked is returned when one of the users has blocked the other
+	ErrUserBlocked = errors.New("you cannot challenge this user")
+	// ErrChallengeNotFound is returned when the challenge does not exist, has expired or is not for the user
+	ErrChallengeNotFound = errors.New("challenge not found")
+	// ErrUserInGame is returned when the challenged user is already in a game
+	ErrUserInGame = errors.New("user is already in a game")
+
+	challengesMutex sync.Mutex
+	challenges      = make(map[string]*Challenge)
+)
+
+// Challenge is an invitation to play sent to a specific user
+type Challenge struct {
+	ID        string
+	From      *WsConnection
+	ToID      primitive.ObjectID
+	To        string
+	ExpiresAt time.Time
+	timer     *time.Timer
+}
+
+// NewChallenge challenges the user to a game with the player.
+// The user must be connected and not in a game. They get a challenge message and have ChallengeTTL to answer.
+func NewChallenge(from *WsConnection, username string) (*Challenge, error) {
+	if username == from.Context.User.Username {
+		return nil, ErrChallengeSelf
+	}
+	to := ServerManager().GetByUsername(username)
+	if to == nil {
+		return nil, ErrUserNotConnected
+	}
+	if game := GameManager().FindPlayerGame(from); game != nil && game.IsActive() {
+		return nil, ErrPlayerAlreadyInAnotherGame
+	}
+	if userInGame(to.Context.User.ID) {
+		return nil, ErrUserInGame
+	}
+	blocked, err := blockService.IsBlocked(from.Context.User.ID, to.Context.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrUserBlocked
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	challenge := &Challenge{
+		ID:        id,
+		From:      from,
+		ToID:      to.Context.User.ID,
+		To:        to.Context.User.Username,
+		ExpiresAt: time.Now().Add(ChallengeTTL),
+	}
+
+	challengesMutex.Lock()
+	for _, c := range challenges {
+		if c.From.Context.User.ID == from.Context.User.ID && c.ToID == challenge.ToID {
+			challengesMutex.Unlock()
+			return nil, ErrChallengeExists
+		}
+	}
+	challenges[id] = challenge
+	challenge.timer = time.AfterFunc(ChallengeTTL, func() {
+		if removeChallenge(id) != nil {
+			ServerManager().WriteConnection(challenge.From, newSocketResponseChallenge(responseChallengeExpiredType, challenge, challenge.To))
+			writeUser(challenge.ToID, newSocketResponseChallenge(responseChallengeExpiredType, challenge, challenge.From.Context.User.Username))
+		}
+	})
+	challengesMutex.Unlock()
+
+	writeUser(challenge.ToID, newSocketResponseChallenge(responseChallengeType, challenge, from.Context.User.Username))
+	return challenge, nil
+}
+
+// AcceptChallenge accepts the challenge for the connection of the challenged user and starts the game.
+// If the game cannot start, the challenger is told why.
+func AcceptChallenge(id string, by *WsConnection) error {
+	challengesMutex.Lock()
+	challenge, ok := challenges[id]
+	if !ok || challenge.ToID != by.Context.User.ID {
+		challengesMutex.Unlock()
+		return ErrChallengeNotFound
+	}
+	delete(challenges, id)
+	challenge.timer.Stop()
+	challengesMutex.Unlock()
+
+	GameManager().RemoveSearcher(challenge.From)
+	GameManager().RemoveSearcher(by)
+	if err := GameManager().NewGame(challenge.From, by, DefaultGameLength, nil); err != nil {
+		ServerManager().WriteConnection(challenge.From, SocketResponseError{Error: err.Error()})
+		return err
+	}
+	return nil
+}
+
+// DeclineChallenge declines the challenge for the challenged user, or cancels it for the challenger
+func DeclineChallenge(id string, userID primitive.ObjectID) error {
+	challengesMutex.Lock()
+	challenge, ok := challenges[id]
+	if !ok || (challenge.ToID != userID && challenge.From.Context.User.ID != userID) {
+		challengesMutex.Unlock()
+		return ErrChallengeNotFound
+	}
+	delete(challenges, id)
+	challenge.timer.Stop()
+	challengesMutex.Unlock()
+
+	if challenge.ToID == userID {
+		ServerManager().WriteConnection(challenge.From, newSocketResponseChallenge(responseChallengeDeclinedType, challenge, challenge.To))
+	} else {
+		writeUser(challenge.ToID, newSocketResponseChallenge(responseChallengeCancelledType, challenge, challenge.From.Context.User.Username))
+	}
+	return nil
+}
+
+// cancelChallenges cancels the challenges sent from the connection
+func cancelChallenges(from *WsConnection) {
+	challengesMutex.Lock()
+	var cancelled []*Challenge
+	for id, c := range challenges {
+		if c.From == from {
+			delete(challenges, id)
+			c.timer.Stop()
+			cancelled = append(cancelled, c)
+		}
+	}
+	challengesMutex.Unlock()
+
+	for _, c := range cancelled {
+		writeUser(c.ToID, newSocketResponseChallenge(responseChallengeCancelledType, c, c.From.Context.User.Username))
+	}
+}
+
+func removeChallenge(id string) *Challenge {
+	challengesMutex.Lock()
+	defer challengesMutex.Unlock()
+	challenge, ok := challenges[id]
+	if !ok {
+		return nil
+	}
+	delete(challenges, id)
+	return challenge
+}
+
+// userInGame returns true if a connection of the user is in a game, started or about to start
+func userInGame(userID primitive.ObjectID) bool {
+	for _, conn := range ServerManager().UserConnections(userID) {
+		if GameManager().FindPlayerGame(conn) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeUser writes the data to every connection of the user
+func writeUser(userID primitive.ObjectID, data interface{}) {
+	for _, conn := range ServerManager().UserConnections(userID) {
+		ServerManager().WriteConnection(conn, data)
+	}
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleChallengeMessage challenges a user
+func handleChallengeMessage(wsConnection *WsConnection, msg SocketMessageChallenge) {
+	challenge, err := NewChallenge(wsConnection, msg.Username)
+	if err != nil {
+		ServerManager().WriteConnection(wsConnection, SocketResponseError{Error: err.Error()})
+		return
+	}
+	ServerManager().WriteConnection(wsConnection, newSocketResponseChallenge(responseChallengeSentType, challenge, challenge.To))
+}
+
+// handleChallengeAcceptMessage accepts a challenge
+func handleChallengeAcceptMessage(wsConnection *WsConnection, msg SocketMessageChallengeAnswer) {
+	if err := AcceptChallenge(msg.ID, wsConnection); err != nil {
+		log.Info(err)
+		ServerManager().WriteConnection(wsConnection, SocketResponseError{Error: err.Error()})
+	}
+}
+
+// handleChallengeDeclineMessage declines or cancels a challenge
+func handleChallengeDeclineMessage(wsConnection *WsConnection, msg SocketMessageChallengeAnswer) {
+	if err := DeclineChallenge(msg.ID, wsConnection.Context.User.ID); err != nil {
+		ServerManager().WriteConnection(wsConnection, SocketResponseError{Error: err.Error()})
+	}
+}
+
+// SocketMessageChallenge challenges the user
+type SocketMessageChallenge struct {
+	Username string `json:"username"`
+}
+
+// SocketMessageChallengeAnswer accepts or declines a challenge
+type SocketMessageChallengeAnswer struct {
+	ID string `json:"id"`
+}
+
+// SocketResponseChallenge is sent to both users when a challenge is sent, answered or expires.
+// Username is the other user.
+type SocketResponseChallenge struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+func newSocketResponseChallenge(responseType string, challenge *Challenge, username string) SocketResponseChallenge {
+	return SocketResponseChallenge{
+		Type:      responseType,
+		ID:        challenge.ID,
+		Username:  username,
+		ExpiresAt: challenge.ExpiresAt.Unix(),
+	}
+}
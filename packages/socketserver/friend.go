@@ -0,0 +1,40 @@
+package socketserver
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	responseFriendRequestType  = "friendRequest"
+	responseFriendAcceptedType = "friendAccepted"
+)
+
+// NotifyFriendRequest tells the user that they received a friend request
+func NotifyFriendRequest(userID primitive.ObjectID, from string) {
+	response := SocketResponseFriend{
+		Type:     responseFriendRequestType,
+		Username: from,
+	}
+	for _, conn := range ServerManager().UserConnections(userID) {
+		ServerManager().WriteConnection(conn, response)
+	}
+}
+
+// NotifyFriendAccepted tells the user that their friend request was accepted, with the presence of the new friend
+func NotifyFriendAccepted(userID primitive.ObjectID, by string, byID primitive.ObjectID) {
+	response := SocketResponseFriend{
+		Type:     responseFriendAcceptedType,
+		Username: by,
+		Presence: UserPresence(byID),
+	}
+	for _, conn := range ServerManager().UserConnections(userID) {
+		ServerManager().WriteConnection(conn, response)
+	}
+}
+
+// SocketResponseFriend is pushed when a friend request is received or accepted
+type SocketResponseFriend struct {
+	Type     string   `json:"type"`
+	Username string   `json:"username"`
+	Presence Presence `json:"presence,omitempty"`
+}
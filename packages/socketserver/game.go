@@ -2,56 +2,164 @@ package socketserver
 
 import (
 	"errors"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/blobstore"
+	"github.com/labstack/gommon/log"
 )
 
 // RoundResult represents the result of a game round
 type RoundResult struct {
 	QuestionIndex int
 	Question      string
+	// CorrectAnswer is only revealed to the players with the result of the round
+	CorrectAnswer string
 	Answers       map[*WsConnection]string
 	Times         map[*WsConnection]time.Time
 	Scores        map[*WsConnection]float64
+	Correct       map[*WsConnection]bool
+	// distances are how far the players are from the answer of a numeric question
+	distances map[*WsConnection]float64
+	// finalized is set once the result is sent, after which answers are ignored
+	finalized bool
 }
 
 // Game represents the game between players
 type Game struct {
-	Active       bool
+	// pending is true from the creation of the game until it starts or is cancelled, see Leave
+	pending bool
+	// active is true while the game is played, see IsActive
+	active       bool
 	Players      []*WsConnection
 	Questions    []*models.Question
 	Cursor       int
 	RoundResults []*RoundResult
 	RoundTimes   []time.Time
 	Winnner      string
+	StartedAt    time.Time
+	// preloads are the media of the rounds, nil for rounds without media
+	preloads []*mediaPreload
+	// mutex guards the rounds against the answers of both players arriving together,
+	// and whether the game is active against players leaving while it ends
+	mutex sync.Mutex
 }
 
+// mediaPreload is the media of a round and the players who have loaded it
+type mediaPreload struct {
+	// media is set once it is sent to the players
+	media *SocketMedia
+	ready map[*WsConnection]bool
+	// done is closed when every player has loaded the media
+	done chan struct{}
+}
+
+const (
+	// roundSeconds is the time to answer a question. Correct answers score the seconds left.
+	roundSeconds = 10
+	// preloadTimeout is how long a round with media waits for the players to load it
+	preloadTimeout = 5 * time.Second
+	// mediaURLTTL is how long the media URLs sent to the players can be used
+	mediaURLTTL = 30 * time.Minute
+)
+
 var (
 	ErrGameIsStillRunning = errors.New("game is still running. Try again with force option")
+	// ErrOpponentLeft is sent to a player whose opponent left before the game started
+	ErrOpponentLeft = errors.New("opponent left before the game started")
 )
 
 // newGame creates a new game
 func newGame(player1 *WsConnection, player2 *WsConnection, questions []*models.Question) *Game {
 	g := &Game{
-		Active:     false,
+		pending:    true,
+		active:     false,
 		Players:    []*WsConnection{player1, player2},
 		Questions:  questions,
 		Cursor:     0,
 		RoundTimes: make([]time.Time, len(questions)),
+		preloads:   make([]*mediaPreload, len(questions)),
+	}
+	for i, q := range questions {
+		if q.Media != nil {
+			g.preloads[i] = &mediaPreload{
+				ready: make(map[*WsConnection]bool),
+				done:  make(chan struct{}),
+			}
+		}
 	}
 	return g
 }
 
-// Start starts the game
+// Start starts the game, unless it was cancelled or a player is gone
 func (game *Game) Start() {
-	game.Active = true
+	for _, p := range game.Players {
+		if ServerManager().Get(p.Socket) == nil {
+			game.Leave(p)
+			return
+		}
+	}
+	game.mutex.Lock()
+	if !game.pending {
+		game.mutex.Unlock()
+		return
+	}
+	game.pending = false
+	game.active = true
+	game.StartedAt = time.Now()
+	game.mutex.Unlock()
+	go updatePlayersPresence(game)
 	go nextRound(game)
 }
 
+// IsActive returns true while the game is played
+func (game *Game) IsActive() bool {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	return game.active
+}
+
+// end stops the game. It returns false if the game had already stopped,
+// so that only the first of the ways a game can end finishes it.
+func (game *Game) end() bool {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	wasActive := game.active
+	game.active = false
+	return wasActive
+}
+
+// cancel stops the game before it starts. It returns false if the game is not pending.
+func (game *Game) cancel() bool {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	wasPending := game.pending
+	game.pending = false
+	return wasPending
+}
+
+// Leave takes the player out of the game because he disconnected from the server or quit the game.
+// A game that has not started yet is cancelled and the other player is told, otherwise the player looses.
+func (game *Game) Leave(player *WsConnection) {
+	if !game.cancel() {
+		game.PrematureLoose(player)
+		return
+	}
+	GameManager().RemoveGame(game)
+	for _, p := range game.Players {
+		if p != player {
+			ServerManager().WriteConnection(p, SocketResponseError{Error: ErrOpponentLeft.Error()})
+		}
+	}
+}
+
 // PrematureLoose looses the player because he disconnected from the server or quit the game.
 func (game *Game) PrematureLoose(player *WsConnection) {
-	game.Active = false
+	if !game.end() {
+		return
+	}
 	var winner *WsConnection
 	for _, p := range game.Players {
 		if p != player {
@@ -66,32 +174,168 @@ func (game *Game) PrematureLoose(player *WsConnection) {
 	game.Winnner = winner.Context.User.Username
 	response.Winner = game.Winnner
 	broadcast(game, response)
+	game.finish(true)
 	_ = game.Close(true)
 }
 
+// finish records the result of the game and removes it from the manager.
+// It must only be called by whoever ended the game, see end.
+func (game *Game) finish(forfeit bool) {
+	if err := saveResult(game, forfeit); err != nil {
+		log.Errorf("saving game result: %v", err)
+	}
+	GameManager().RemoveGame(game)
+	updatePlayersPresence(game)
+}
+
 // SetRoundResult sets the result submitted by a player for a particular round.
-func (game *Game) SetRoundResult(player *WsConnection, questionIndex int, answer string, timeReceived time.Time) {
+// Only the first answer of a player to the current round counts, see roundScore.
+func (game *Game) SetRoundResult(player *WsConnection, questionIndex int, answer models.Answer, timeReceived time.Time) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if questionIndex < 0 || questionIndex != len(game.RoundResults)-1 {
+		return
+	}
 	roundResult := game.RoundResults[questionIndex]
-	roundResult.Answers[player] = answer
-	roundResult.QuestionIndex = questionIndex
-	roundResult.Times[player] = timeReceived
-	timeTakenSecs := timeReceived.Sub(game.RoundTimes[questionIndex]).Seconds()
-	score := 10 - timeTakenSecs
-	if score < 0 {
-		score = 0
+	if roundResult.finalized {
+		return
+	}
+	if _, ok := roundResult.Answers[player]; ok {
+		return
+	}
+	question := game.Questions[questionIndex]
+	credit := question.Credit(answer)
+	// the closest number is only known when the round closes, so every number close enough is right until then
+	if distance, ok := question.Distance(answer); ok {
+		roundResult.distances[player] = distance
+		if question.InTolerance(distance) {
+			credit = 1
+		}
 	}
-	roundResult.Scores[player] = score
+	roundResult.Answers[player] = answer.String()
+	roundResult.Times[player] = timeReceived
+	roundResult.Correct[player] = credit == 1
+	timeTaken := timeReceived.Sub(game.RoundTimes[questionIndex])
+	roundResult.Scores[player] = roundScore(question, credit, timeTaken)
 
-	isRoundComplete := true
 	for _, player := range game.Players {
 		if _, ok := roundResult.Answers[player]; !ok {
-			isRoundComplete = false
-			break
+			return
+		}
+	}
+	game.closeRound(roundResult)
+}
+
+// preload sends the media of the round to the players, once, so that they can load it before the round starts
+func (game *Game) preload(round int) {
+	game.mutex.Lock()
+	if round >= len(game.preloads) || game.preloads[round] == nil || game.preloads[round].media != nil {
+		game.mutex.Unlock()
+		return
+	}
+	p := game.preloads[round]
+	p.media = newSocketMedia(game.Questions[round].Media)
+	game.mutex.Unlock()
+
+	broadcast(game, SocketResponsePreload{
+		Type:  responsePreloadType,
+		Round: round,
+		Media: p.media,
+	})
+}
+
+// SetMediaReady records that the player has loaded the media of the round
+func (game *Game) SetMediaReady(player *WsConnection, round int) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if round < 0 || round >= len(game.preloads) || game.preloads[round] == nil {
+		return
+	}
+	p := game.preloads[round]
+	if p.ready[player] {
+		return
+	}
+	p.ready[player] = true
+	if len(p.ready) == len(game.Players) {
+		close(p.done)
+	}
+}
+
+// waitForMedia waits until the players have loaded the media of the round, for at most preloadTimeout
+func (game *Game) waitForMedia(round int) {
+	p := game.preloads[round]
+	if p == nil {
+		return
+	}
+	select {
+	case <-p.done:
+	case <-time.After(preloadTimeout):
+	}
+}
+
+// roundResults returns a copy of the results of the rounds played so far, which can be read
+// while late answers and round timers still write to the game
+func (game *Game) roundResults() []*RoundResult {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	res := make([]*RoundResult, len(game.RoundResults))
+	for i, roundResult := range game.RoundResults {
+		c := &RoundResult{
+			QuestionIndex: roundResult.QuestionIndex,
+			Question:      roundResult.Question,
+			CorrectAnswer: roundResult.CorrectAnswer,
+			Answers:       make(map[*WsConnection]string),
+			Times:         make(map[*WsConnection]time.Time),
+			Scores:        make(map[*WsConnection]float64),
+			Correct:       make(map[*WsConnection]bool),
+			finalized:     roundResult.finalized,
+		}
+		for player, answer := range roundResult.Answers {
+			c.Answers[player] = answer
+		}
+		for player, t := range roundResult.Times {
+			c.Times[player] = t
 		}
+		for player, score := range roundResult.Scores {
+			c.Scores[player] = score
+		}
+		for player, correct := range roundResult.Correct {
+			c.Correct[player] = correct
+		}
+		res[i] = c
 	}
-	if isRoundComplete {
-		go finalizeAndGoToNextRound(game, questionIndex)
+	return res
+}
+
+// closeRound stops the round from taking answers and sends its result.
+// Only the closest answers to a numeric question keep their score, if they are close enough, see SetRoundResult.
+// The game must be locked.
+func (game *Game) closeRound(roundResult *RoundResult) {
+	roundResult.finalized = true
+	if len(roundResult.distances) > 0 {
+		closest := math.Inf(1)
+		for _, distance := range roundResult.distances {
+			closest = math.Min(closest, distance)
+		}
+		for player, distance := range roundResult.distances {
+			if distance > closest {
+				roundResult.Scores[player] = 0
+				roundResult.Correct[player] = false
+			}
+		}
 	}
+	go finalizeAndGoToNextRound(game, roundResult.QuestionIndex)
+}
+
+// roundScore is the score of an answer with the credit, from 0 to 1, given after timeTaken.
+// A right answer scores the seconds left to answer times the multiplier of the difficulty of the question.
+// Wrong and late answers score nothing.
+func roundScore(question *models.Question, credit float64, timeTaken time.Duration) float64 {
+	score := roundSeconds - timeTaken.Seconds()
+	if score < 0 {
+		return 0
+	}
+	return credit * score * question.Level().Multiplier()
 }
 
 // finalizeAndGoToNextRound broadcasts the result of the current round and starts the next round
@@ -114,20 +358,37 @@ func broadcast(game *Game, msg interface{}) {
 func (game *Game) Close(force ...bool) error {
 	f := len(force) > 0 && force[0]
 
-	if game.Active && !f {
+	if game.IsActive() && !f {
 		return ErrGameIsStillRunning
 	}
-	game.Active = false
+	game.end()
 	game.Players = []*WsConnection{}
 	return nil
 }
 
 // nextRound starts a new round of the game
 func nextRound(game *Game) {
+	// the game may have been lost by a player leaving
+	if !game.IsActive() {
+		return
+	}
 	round := game.Cursor
 	if round >= len(game.Questions) {
-		game.Active = false
+		if !game.end() {
+			return
+		}
 		broadcast(game, NewSocketResponseGameFinished(game))
+		game.finish(false)
+		return
+	}
+
+	// The media of the round is sent during the previous round, or before the game for the first one,
+	// and the media of the next round is sent now. The round timer only starts once the media is loaded,
+	// so slow downloads don't cost players points.
+	game.preload(round)
+	game.preload(round + 1)
+	game.waitForMedia(round)
+	if !game.IsActive() {
 		return
 	}
 
@@ -135,71 +396,138 @@ func nextRound(game *Game) {
 	roundResult := &RoundResult{
 		QuestionIndex: round,
 		Question:      game.Questions[round].Question,
+		CorrectAnswer: game.Questions[round].Solution(),
 		Answers:       make(map[*WsConnection]string),
 		Times:         make(map[*WsConnection]time.Time),
 		Scores:        make(map[*WsConnection]float64),
+		Correct:       make(map[*WsConnection]bool),
+		distances:     make(map[*WsConnection]float64),
 	}
+	timeSent := time.Now()
+	game.mutex.Lock()
 	game.RoundResults = append(game.RoundResults, roundResult)
+	game.RoundTimes[round] = timeSent
+	game.Cursor++
+	game.mutex.Unlock()
 
-	timeSent := time.Now()
 	//send question to players
-	broadcast(game, NewSocketResponseQuestion(timeSent.Unix(), round, game.Questions[round].Question, game.Questions[round].Answers, game.Questions[round].CorrectAnswer))
-	game.RoundTimes[round] = timeSent
+	question := game.Questions[round]
+	res := NewSocketResponseQuestion(timeSent.Unix(), round, question)
+	if p := game.preloads[round]; p != nil {
+		res.Media = p.media
+	}
+	broadcast(game, res)
 
-	time.AfterFunc(11*time.Second, func() {
-		// nobody has given an answer after 11 secs
-		if len(game.RoundResults[round].Answers) == 0 {
-			go finalizeAndGoToNextRound(game, round)
+	time.AfterFunc((roundSeconds+1)*time.Second, func() {
+		// the players who have not answered after 11 secs get nothing
+		game.mutex.Lock()
+		defer game.mutex.Unlock()
+		if roundResult.finalized {
+			return
 		}
+		game.closeRound(roundResult)
 	})
-	game.Cursor++
 }
 
 // handleAnswerMessage handles the answer message for a question
 func handleAnswerMessage(wsConnection *WsConnection, answer SocketMessageAnswer) {
 	//find game that is running with this connection
 	g := GameManager().FindPlayerGame(wsConnection)
-	if !g.Active {
+	if g == nil || !g.IsActive() {
+		return
+	}
+	g.SetRoundResult(wsConnection, answer.Round, models.Answer{
+		Text:    answer.Answer,
+		Choices: answer.Answers,
+		Number:  answer.Number,
+	}, time.Now())
+}
+
+// handleMediaReadyMessage handles the message of a player who has loaded the media of a round
+func handleMediaReadyMessage(wsConnection *WsConnection, msg SocketMessageMediaReady) {
+	g := GameManager().FindPlayerGame(wsConnection)
+	if g == nil || !g.IsActive() {
 		return
 	}
-	g.SetRoundResult(wsConnection, answer.Round, answer.Answer, time.Now())
+	g.SetMediaReady(wsConnection, msg.Round)
 }
 
 // handleQuitMessage handles a quit message
 func handleQuitMessage(connection *WsConnection, _ SocketMessageQuit) {
 	g := GameManager().FindPlayerGame(connection)
-	if !g.Active {
+	if g == nil {
 		return
 	}
-	g.PrematureLoose(connection)
+	g.Leave(connection)
 }
 
 const responseQuestionType = "question"
 const responseGameFinishedType = "gameFinished"
 const responseRoundResultType = "roundResult"
+const responsePreloadType = "preload"
 
-// SocketResponseQuestion represents a question
+// SocketResponseQuestion represents a question.
+// Answers are the options of single choice, true/false and multi-select questions.
+// The correct answer is sent with the result of the round, see SocketResponseRoundResult.
+// Media is the image or sound of the question, already sent by a preload message.
 type SocketResponseQuestion struct {
-	Type          string   `json:"type"`
-	Time          int64    `json:"time"`
-	Round         int      `json:"round"`
-	Question      string   `json:"question"`
-	Answers       []string `json:"answers"`
-	CorrectAnswer string   `json:"correctAnswer"`
+	Type         string       `json:"type"`
+	Time         int64        `json:"time"`
+	Round        int          `json:"round"`
+	Question     string       `json:"question"`
+	QuestionType string       `json:"questionType"`
+	Answers      []string     `json:"answers"`
+	Difficulty   string       `json:"difficulty"`
+	Media        *SocketMedia `json:"media,omitempty"`
+}
+
+// SocketMedia is the image or sound of a question. URL is signed and expires after mediaURLTTL.
+type SocketMedia struct {
+	Type        string `json:"type"`
+	ContentType string `json:"contentType"`
+	URL         string `json:"url"`
+}
+
+// newSocketMedia returns the media with a signed URL. The URL is left out if it cannot be signed.
+func newSocketMedia(media *models.Media) *SocketMedia {
+	mediaURL, err := blobstore.Instance().SignedURL(media.Key, mediaURLTTL)
+	if err != nil {
+		log.Errorf("signing media %s: %v", media.Key, err)
+	}
+	return &SocketMedia{
+		Type:        string(media.Type),
+		ContentType: media.ContentType,
+		URL:         mediaURL,
+	}
+}
+
+// SocketResponsePreload is the media of a round, sent ahead of its question so that it can be loaded in time.
+// The round starts when every player has answered with SocketMessageMediaReady, or after preloadTimeout.
+type SocketResponsePreload struct {
+	Type  string       `json:"type"`
+	Round int          `json:"round"`
+	Media *SocketMedia `json:"media"`
+}
+
+// SocketMessageMediaReady tells that the media of the round is loaded
+type SocketMessageMediaReady struct {
+	Round int `json:"round"`
 }
 
 type Result struct {
-	Answer string    `json:"string"`
-	Time   time.Time `json:"time"`
-	Score  float64   `json:"score"`
+	Answer  string    `json:"string"`
+	Time    time.Time `json:"time"`
+	Score   float64   `json:"score"`
+	Correct bool      `json:"correct"`
 }
 
-// SocketResponseRoundResult is the result of players of a round
+// SocketResponseRoundResult is the result of players of a round, with the correct answer
 type SocketResponseRoundResult struct {
-	Type     string             `json:"type"`
-	Round    int                `json:"round"`
-	Question string             `json:"question"`
-	Results  map[string]*Result `json:"results"`
+	Type          string             `json:"type"`
+	Round         int                `json:"round"`
+	Question      string             `json:"question"`
+	CorrectAnswer string             `json:"correctAnswer"`
+	Results       map[string]*Result `json:"results"`
 }
 
 // NewSocketResponseRoundResult returns a NewSocketResponseRoundResult
@@ -216,31 +544,40 @@ func NewSocketResponseRoundResult(result *RoundResult) SocketResponseRoundResult
 	for player, score := range result.Scores {
 		results[player.Context.User.Username].Score = score
 	}
+	for player, correct := range result.Correct {
+		results[player.Context.User.Username].Correct = correct
+	}
 	return SocketResponseRoundResult{
-		Type:     responseRoundResultType,
-		Round:    result.QuestionIndex,
-		Question: result.Question,
-		Results:  results,
+		Type:          responseRoundResultType,
+		Round:         result.QuestionIndex,
+		Question:      result.Question,
+		CorrectAnswer: result.CorrectAnswer,
+		Results:       results,
 	}
 }
 
 // NewSocketResponseQuestion returns a new NewSocketResponseQuestion
-func NewSocketResponseQuestion(time int64, round int, question string, answers []string, correctAnswer string) SocketResponseQuestion {
+func NewSocketResponseQuestion(time int64, round int, question *models.Question) SocketResponseQuestion {
 	return SocketResponseQuestion{
-		Type:          responseQuestionType,
-		Time:          time,
-		Round:         round,
-		Question:      question,
-		Answers:       answers,
-		CorrectAnswer: correctAnswer,
+		Type:         responseQuestionType,
+		Time:         time,
+		Round:        round,
+		Question:     question.Question,
+		QuestionType: string(question.Kind()),
+		Answers:      question.Answers,
+		Difficulty:   string(question.Level()),
 	}
 }
 
 // SocketMessageAnswer represents the answer to a specific round of the questions.
+// Answer is the answer to single choice, true/false and free text questions, Answers the choices
+// for a multi-select question and Number the answer to a numeric question.
 type SocketMessageAnswer struct {
-	Round    int    `json:"round"`
-	Answer   string `json:"answer"`
-	Question string `json:"question"`
+	Round    int      `json:"round"`
+	Answer   string   `json:"answer"`
+	Answers  []string `json:"answers,omitempty"`
+	Number   *float64 `json:"number,omitempty"`
+	Question string   `json:"question"`
 }
 
 // SocketMessageQuit represents a quit game message
@@ -259,7 +596,7 @@ type SocketResponseGameFinished struct {
 func NewSocketResponseGameFinished(game *Game) SocketResponseGameFinished {
 	var roundResults []SocketResponseRoundResult
 	totals := make(map[string]float64)
-	for _, roundResult := range game.RoundResults {
+	for _, roundResult := range game.roundResults() {
 		round := NewSocketResponseRoundResult(roundResult)
 		roundResults = append(roundResults, round)
 		for username, result := range round.Results {
@@ -267,11 +604,15 @@ func NewSocketResponseGameFinished(game *Game) SocketResponseGameFinished {
 		}
 	}
 
+	// the player with the highest total wins. There is no winner on a tie.
 	maxScore := 0.0
 	winner := ""
 	for username, totalScore := range totals {
 		if totalScore > maxScore {
+			maxScore = totalScore
 			winner = username
+		} else if totalScore == maxScore {
+			winner = ""
 		}
 	}
 	game.Winnner = winner
@@ -5,12 +5,21 @@ import (
 	"sync"
 	"time"
 
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
 	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
 	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultGameLength defines the number of questions in a game
+	DefaultGameLength = 10
 )
 
 var (
 	searchingMutex sync.Mutex
+	gamesMutex     sync.Mutex
 	gameManager    *GManager
 	gManagerOnce   sync.Once
 )
@@ -24,10 +33,17 @@ var (
 
 // ServerManager is the game manager
 type GManager struct {
-	searching []*WsConnection
+	searching []*searcher
 	games     []*Game
 }
 
+// searcher is a player looking for an opponent, with the categories they want to play.
+// No categories means any category.
+type searcher struct {
+	player     *WsConnection
+	categories []primitive.ObjectID
+}
+
 // GameManager returns the manager instance
 func GameManager() *GManager {
 	gManagerOnce.Do(func() {
@@ -42,7 +58,7 @@ func (mgr *GManager) Games(isActive ...bool) []*Game {
 	if len(isActive) > 0 {
 		condition := isActive[0]
 		for _, game := range mgr.games {
-			if game.Active == condition {
+			if game.IsActive() == condition {
 				res = append(res, game)
 			}
 		}
@@ -55,6 +71,13 @@ func (mgr *GManager) Games(isActive ...bool) []*Game {
 
 // FindPlayerGame finds the game that contains the specified player
 func (mgr *GManager) FindPlayerGame(player *WsConnection) *Game {
+	gamesMutex.Lock()
+	defer gamesMutex.Unlock()
+	return mgr.findPlayerGame(player)
+}
+
+// findPlayerGame finds the game that contains the specified player. The games must be locked.
+func (mgr *GManager) findPlayerGame(player *WsConnection) *Game {
 	for _, game := range mgr.games {
 		for _, p := range game.Players {
 			if p == player {
@@ -65,16 +88,18 @@ func (mgr *GManager) FindPlayerGame(player *WsConnection) *Game {
 	return nil
 }
 
-// NewGame creates and starts a new game between 2 players and a specified number of questions.
+// NewGame creates a new game between 2 players and a specified number of questions
+// drawn from the categories, or from all questions if there are no categories, see selectQuestions.
+// The game starts a few seconds later, once the players are ready, without blocking the caller.
 // If there are not upto `length` questions available, it returns with error.
 // If any of the members is already in a game, it returns with error.
-func (mgr *GManager) NewGame(player1 *WsConnection, player2 *WsConnection, length int) error {
+func (mgr *GManager) NewGame(player1 *WsConnection, player2 *WsConnection, length int, categories []primitive.ObjectID) error {
 	// check that players are not already in another game
 	if found1, found2 := mgr.FindPlayerGame(player1), mgr.FindPlayerGame(player2); found1 != nil || found2 != nil {
 		return ErrPlayerAlreadyInAnotherGame
 	}
 	// find questions
-	questions, err := questionService.FindAll()
+	questions, err := selectQuestions([]*WsConnection{player1, player2}, categories, length)
 	if err != nil {
 		log.Info(err)
 		return err
@@ -83,30 +108,51 @@ func (mgr *GManager) NewGame(player1 *WsConnection, player2 *WsConnection, lengt
 		return ErrNotEnoughQuestions
 	}
 
-	questions = questions[:length]
 	g := newGame(player1, player2, questions)
+	gamesMutex.Lock()
+	// the players may have joined another game while the questions were drawn
+	if mgr.findPlayerGame(player1) != nil || mgr.findPlayerGame(player2) != nil {
+		gamesMutex.Unlock()
+		return ErrPlayerAlreadyInAnotherGame
+	}
 	mgr.games = append(mgr.games, g)
+	gamesMutex.Unlock()
 
 	// Tell players game is about to start
 	ServerManager().WriteConnection(player1, NewSocketResponseOpponentFound(player2.Context.User.Username))
 	ServerManager().WriteConnection(player2, NewSocketResponseOpponentFound(player1.Context.User.Username))
+	// the media of the first round loads while the players get ready
+	g.preload(0)
 
-	// wait a bit for players to prepare
-	time.Sleep(3 * time.Second)
-
-	g.Start()
+	// wait a bit for players to prepare, without holding up the caller
+	go func() {
+		time.Sleep(3 * time.Second)
+		g.Start()
+	}()
 	return nil
 }
 
-// AddSearcher adds a player to the searching queue
+// RemoveGame removes a finished game
+func (mgr *GManager) RemoveGame(game *Game) {
+	gamesMutex.Lock()
+	defer gamesMutex.Unlock()
+	for i, g := range mgr.games {
+		if g == game {
+			mgr.games = append(mgr.games[:i], mgr.games[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddSearcher adds a player to the searching queue, with the categories they want to play
 // If the player is already searching, it returns with error.
-func (mgr *GManager) AddSearcher(player *WsConnection) error {
+func (mgr *GManager) AddSearcher(player *WsConnection, categories []primitive.ObjectID) error {
 	searchingMutex.Lock()
 	defer searchingMutex.Unlock()
 
 	isAlreadySearching := false
-	for _, p := range mgr.searching {
-		if p == player {
+	for _, s := range mgr.searching {
+		if s.player == player {
 			isAlreadySearching = true
 			break
 		}
@@ -114,7 +160,10 @@ func (mgr *GManager) AddSearcher(player *WsConnection) error {
 	if isAlreadySearching {
 		return ErrAlreadySearching
 	}
-	mgr.searching = append(mgr.searching, player)
+	mgr.searching = append(mgr.searching, &searcher{
+		player:     player,
+		categories: categories,
+	})
 	return nil
 }
 
@@ -123,8 +172,8 @@ func (mgr *GManager) RemoveSearcher(player *WsConnection) {
 	searchingMutex.Lock()
 	defer searchingMutex.Unlock()
 	pos := -1
-	for i, v := range mgr.searching {
-		if v == player {
+	for i, s := range mgr.searching {
+		if s.player == player {
 			pos = i
 			break
 		}
@@ -136,17 +185,89 @@ func (mgr *GManager) RemoveSearcher(player *WsConnection) {
 	mgr.searching = append(temp, mgr.searching[pos+1:]...)
 }
 
-// GetPair returns the 1st two players in the searching queue.
+// GetPair returns the first two players in the searching queue that want compatible categories
+// and have not blocked each other, with the categories of their game of `length` questions.
 // It nil for both if the pair cannot be formed.
-func (mgr *GManager) GetPair() (player1 *WsConnection, player2 *WsConnection) {
-	if len(mgr.searching) < 2 {
-		return
+func (mgr *GManager) GetPair(length int) (player1 *WsConnection, player2 *WsConnection, categories []primitive.ObjectID) {
+	searchingMutex.Lock()
+	defer searchingMutex.Unlock()
+	for i := 0; i < len(mgr.searching); i++ {
+		for j := i + 1; j < len(mgr.searching); j++ {
+			s1, s2 := mgr.searching[i], mgr.searching[j]
+			shared, ok, err := sharedCategories(s1.categories, s2.categories, length)
+			if err != nil {
+				log.Errorf("%v", err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			blocked, err := blockService.IsBlocked(s1.player.Context.User.ID, s2.player.Context.User.ID)
+			if err != nil {
+				log.Errorf("%v", err)
+				continue
+			}
+			if blocked {
+				continue
+			}
+			player1, player2, categories = s1.player, s2.player, shared
+			remaining := append([]*searcher{}, mgr.searching[:i]...)
+			remaining = append(remaining, mgr.searching[i+1:j]...)
+			mgr.searching = append(remaining, mgr.searching[j+1:]...)
+			return
+		}
 	}
-	player1, player2 = mgr.searching[0], mgr.searching[1]
-	mgr.searching = mgr.searching[2:]
 	return
 }
 
+// sharedCategories returns the categories of a game of `length` questions between players who want
+// the categories c1 and c2. A player without categories takes any, so the game uses those of the other player.
+// If the categories in common hold less than `length` questions, the game uses the categories of both players,
+// which hold enough on their own.
+// It returns false if both players want categories but none in common.
+func sharedCategories(c1 []primitive.ObjectID, c2 []primitive.ObjectID, length int) ([]primitive.ObjectID, bool, error) {
+	if len(c1) == 0 {
+		return c2, true, nil
+	}
+	if len(c2) == 0 {
+		return c1, true, nil
+	}
+	var shared []primitive.ObjectID
+	for _, a := range c1 {
+		for _, b := range c2 {
+			if a == b {
+				shared = append(shared, a)
+				break
+			}
+		}
+	}
+	if len(shared) == 0 {
+		return nil, false, nil
+	}
+
+	count, err := questionService.Count(bson.D{{Key: "categoryId", Value: bson.D{{Key: "$in", Value: shared}}}})
+	if err != nil {
+		return nil, false, err
+	}
+	if count >= int64(length) {
+		return shared, true, nil
+	}
+	union := append([]primitive.ObjectID{}, c1...)
+	for _, b := range c2 {
+		found := false
+		for _, a := range c1 {
+			if a == b {
+				found = true
+				break
+			}
+		}
+		if !found {
+			union = append(union, b)
+		}
+	}
+	return union, true, nil
+}
+
 const opponentFoundType = "opponentFound"
 
 // SocketResponseOpponentFound represents the user found by search
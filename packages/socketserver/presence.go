@@ -0,0 +1,148 @@
+package socketserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	friendshipService "github.com/acha-bill/quizzer_backend/packages/dblayer/friendship"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Presence tells friends whether a user is around
+type Presence string
+
+const (
+	// PresenceOffline is a user without a socket
+	PresenceOffline Presence = "offline"
+	// PresenceOnline is a user with an active socket
+	PresenceOnline Presence = "online"
+	// PresenceIdle is a user whose sockets have been quiet for idleAfter
+	PresenceIdle Presence = "idle"
+	// PresenceInGame is a user playing a game
+	PresenceInGame Presence = "in_game"
+
+	// idleAfter is how long a socket can stay quiet before its user is idle
+	idleAfter = 5 * time.Minute
+	// presenceInterval is how often idle users are detected
+	presenceInterval = 30 * time.Second
+
+	responsePresenceType = "presence"
+)
+
+var (
+	presenceMutex sync.Mutex
+	// presences holds the last presence pushed to the friends of each online user
+	presences = make(map[primitive.ObjectID]userPresence)
+)
+
+type userPresence struct {
+	username string
+	presence Presence
+}
+
+// touch records activity on the connection
+func (wsCon *WsConnection) touch() {
+	atomic.StoreInt64(&wsCon.lastActivity, time.Now().UnixNano())
+}
+
+// LastActivity returns when the connection was last used
+func (wsCon *WsConnection) LastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&wsCon.lastActivity))
+}
+
+// UserPresence returns the presence of the user, derived from their connections and games
+func UserPresence(userID primitive.ObjectID) Presence {
+	connections := ServerManager().UserConnections(userID)
+	if len(connections) == 0 {
+		return PresenceOffline
+	}
+	var lastActivity time.Time
+	for _, conn := range connections {
+		if game := GameManager().FindPlayerGame(conn); game != nil && game.IsActive() {
+			return PresenceInGame
+		}
+		if conn.LastActivity().After(lastActivity) {
+			lastActivity = conn.LastActivity()
+		}
+	}
+	if time.Since(lastActivity) > idleAfter {
+		return PresenceIdle
+	}
+	return PresenceOnline
+}
+
+// updatePresence recomputes the presence of the user and pushes it to their online friends if it changed
+func updatePresence(userID primitive.ObjectID, username string) {
+	presence := UserPresence(userID)
+
+	presenceMutex.Lock()
+	previous := PresenceOffline
+	if p, ok := presences[userID]; ok {
+		previous = p.presence
+	}
+	if presence == PresenceOffline {
+		delete(presences, userID)
+	} else {
+		presences[userID] = userPresence{username: username, presence: presence}
+	}
+	presenceMutex.Unlock()
+
+	if presence == previous {
+		return
+	}
+	friendIDs, err := friendshipService.FriendIDs(userID)
+	if err != nil {
+		log.Errorf("%v", err)
+		return
+	}
+	response := NewSocketResponsePresence(username, presence)
+	for _, friendID := range friendIDs {
+		for _, conn := range ServerManager().UserConnections(friendID) {
+			ServerManager().WriteConnection(conn, response)
+		}
+	}
+}
+
+// WatchPresence periodically pushes the users that became idle to their friends.
+// Other presence changes are pushed as they happen.
+func WatchPresence() {
+	for range time.Tick(presenceInterval) {
+		presenceMutex.Lock()
+		users := make(map[primitive.ObjectID]string)
+		for userID, p := range presences {
+			users[userID] = p.username
+		}
+		presenceMutex.Unlock()
+
+		for userID, username := range users {
+			updatePresence(userID, username)
+		}
+	}
+}
+
+// updatePlayersPresence updates the presence of the players of the game
+func updatePlayersPresence(game *Game) {
+	for _, player := range game.Players {
+		if player.Context.User != nil {
+			updatePresence(player.Context.User.ID, player.Context.User.Username)
+		}
+	}
+}
+
+// SocketResponsePresence is pushed to the friends of a user when their presence changes
+type SocketResponsePresence struct {
+	Type     string   `json:"type"`
+	Username string   `json:"username"`
+	Presence Presence `json:"presence"`
+}
+
+// NewSocketResponsePresence returns a new SocketResponsePresence
+func NewSocketResponsePresence(username string, presence Presence) SocketResponsePresence {
+	return SocketResponsePresence{
+		Type:     responsePresenceType,
+		Username: username,
+		Presence: presence,
+	}
+}
@@ -0,0 +1,114 @@
+package socketserver
+
+import (
+	"math"
+	"sort"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// minHistory is the number of questions a player must have answered before their accuracy counts
+	minHistory = 20
+	// expectedAccuracy is the accuracy of a player for whom the default curve is right
+	expectedAccuracy = 0.6
+)
+
+// selectQuestions draws `length` questions for a game between the players, from the categories or from any
+// category if there are none. The difficulty rises from easy to hard across the rounds, and the whole curve
+// is shifted towards easier or harder questions for players whose past accuracy is low or high.
+// Missing difficulties are made up with questions of any difficulty.
+func selectQuestions(players []*WsConnection, categories []primitive.ObjectID, length int) ([]*models.Question, error) {
+	base := bson.D{}
+	if len(categories) > 0 {
+		base = append(base, bson.E{Key: "categoryId", Value: bson.D{{Key: "$in", Value: categories}}})
+	}
+
+	wanted := make(map[models.Difficulty]int)
+	for _, difficulty := range difficultyCurve(length, skill(players)) {
+		wanted[difficulty]++
+	}
+	var questions []*models.Question
+	ids := []primitive.ObjectID{}
+	for _, difficulty := range models.Difficulties {
+		if wanted[difficulty] == 0 {
+			continue
+		}
+		filter := append(bson.D{questionService.DifficultyFilter(difficulty)}, base...)
+		found, err := questionService.Sample(filter, wanted[difficulty])
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			questions = append(questions, q)
+			ids = append(ids, q.ID)
+		}
+	}
+	if missing := length - len(questions); missing > 0 {
+		filter := append(bson.D{{Key: "_id", Value: bson.D{{Key: "$nin", Value: ids}}}}, base...)
+		found, err := questionService.Sample(filter, missing)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, found...)
+	}
+
+	sort.SliceStable(questions, func(i, j int) bool {
+		return difficultyRank(questions[i].Level()) < difficultyRank(questions[j].Level())
+	})
+	return questions, nil
+}
+
+// difficultyCurve returns the difficulty of each round. Without skill it goes evenly from easy to hard.
+// skill, between -1 and 1, moves every round towards easy or hard.
+func difficultyCurve(length int, skill float64) []models.Difficulty {
+	hardest := float64(len(models.Difficulties) - 1)
+	curve := make([]models.Difficulty, length)
+	for i := range curve {
+		progress := 0.5
+		if length > 1 {
+			progress = float64(i) / float64(length-1)
+		}
+		level := math.Round(progress*hardest + skill)
+		level = math.Max(0, math.Min(hardest, level))
+		curve[i] = models.Difficulties[int(level)]
+	}
+	return curve
+}
+
+// skill returns how much the players are above or below the expected accuracy, between -1 and 1.
+// Players without enough history are taken to have the expected accuracy.
+func skill(players []*WsConnection) float64 {
+	total := 0.0
+	for _, player := range players {
+		accuracy := expectedAccuracy
+		if player.Context.User != nil {
+			stats, err := gameResultService.Stats(player.Context.User.ID)
+			if err != nil {
+				log.Errorf("%v", err)
+			} else if stats.Questions >= minHistory {
+				accuracy = float64(stats.Correct) / float64(stats.Questions)
+			}
+		}
+		total += accuracy
+	}
+	if len(players) == 0 {
+		return 0
+	}
+	s := (total/float64(len(players)) - expectedAccuracy) * 2.5
+	return math.Max(-1, math.Min(1, s))
+}
+
+func difficultyRank(d models.Difficulty) int {
+	for i, difficulty := range models.Difficulties {
+		if d == difficulty {
+			return i
+		}
+	}
+	return 1
+}
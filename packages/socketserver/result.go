@@ -0,0 +1,63 @@
+package socketserver
+
+import (
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// saveResult persists the result of the game for the statistics of its players.
+// forfeit is true if the game ended because a player left.
+func saveResult(game *Game, forfeit bool) error {
+	categoryNames, err := categoryService.Names()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	result := models.GameResult{
+		ID:         primitive.NewObjectID(),
+		Winner:     game.Winnner,
+		Forfeit:    forfeit,
+		StartedAt:  game.StartedAt,
+		FinishedAt: now,
+		CreatedAt:  now,
+	}
+
+	// the time of a round is set with its result, so it is known for every round of the copy
+	roundResults := game.roundResults()
+	for _, player := range game.Players {
+		if player.Context.User == nil {
+			continue
+		}
+		playerResult := models.PlayerResult{
+			UserID:   player.Context.User.ID,
+			Username: player.Context.User.Username,
+			Won:      game.Winnner != "" && game.Winnner == player.Context.User.Username,
+			Lost:     game.Winnner != "" && game.Winnner != player.Context.User.Username,
+		}
+		for _, roundResult := range roundResults {
+			question := game.Questions[roundResult.QuestionIndex]
+			answer, answered := roundResult.Answers[player]
+			answerResult := models.AnswerResult{
+				QuestionID: question.ID,
+				Category:   categoryNames[question.CategoryID],
+				Difficulty: question.Level(),
+				Answer:     answer,
+				Answered:   answered,
+				Correct:    answered && roundResult.Correct[player],
+			}
+			if answered {
+				answerResult.ResponseMillis = roundResult.Times[player].Sub(game.RoundTimes[roundResult.QuestionIndex]).Milliseconds()
+			}
+			playerResult.Score += roundResult.Scores[player]
+			playerResult.Answers = append(playerResult.Answers, answerResult)
+		}
+		result.Players = append(result.Players, playerResult)
+	}
+
+	_, err = gameResultService.Create(result)
+	return err
+}
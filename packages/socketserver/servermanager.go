@@ -3,18 +3,23 @@ package socketserver
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
+	"reflect"
 	"sync"
 
 	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/token"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var (
-	upgrader               = websocket.Upgrader{}
+	upgrader               = websocket.Upgrader{Subprotocols: []string{Subprotocol}}
 	ErrWritingToConnection = errors.New("error writing to connection")
 	ErrUnknownMessageType  = errors.New("unknown message type")
+	ErrInvalidMessage      = errors.New("invalid message payload")
 	msgTypeMap             map[string]interface{}
 	once                   sync.Once
 	mutex                  sync.Mutex
@@ -26,6 +31,12 @@ const (
 	MessageTypePing   = "ping"
 	MessageTypeAnswer = "answer"
 	MessageTypeQuit   = "quit"
+	// MessageTypeMediaReady tells that the media of a round is loaded, see SocketResponsePreload
+	MessageTypeMediaReady = "mediaReady"
+
+	MessageTypeChallenge        = "challenge"
+	MessageTypeChallengeAccept  = "challengeAccept"
+	MessageTypeChallengeDecline = "challengeDecline"
 )
 
 func init() {
@@ -34,18 +45,27 @@ func init() {
 	msgTypeMap[MessageTypePing] = nil
 	msgTypeMap[MessageTypeAnswer] = SocketMessageAnswer{}
 	msgTypeMap[MessageTypeQuit] = SocketMessageQuit{}
+	msgTypeMap[MessageTypeMediaReady] = SocketMessageMediaReady{}
+	msgTypeMap[MessageTypeChallenge] = SocketMessageChallenge{}
+	msgTypeMap[MessageTypeChallengeAccept] = SocketMessageChallengeAnswer{}
+	msgTypeMap[MessageTypeChallengeDecline] = SocketMessageChallengeAnswer{}
 }
 
 // WsContext is the context of a socket connection
 type WsContext struct {
-	Ready bool
-	User  *models.User
+	User *models.User
+	// Family is the token family of the session the socket authenticated with.
+	// It is read and written under the manager mutex, see SetFamily.
+	Family string
 }
 
 // WsConnection represents the websocket connection.
 type WsConnection struct {
-	Socket  *websocket.Conn
-	Context *WsContext
+	// lastActivity is the unix time in nanoseconds of the last message. Keep it first for atomic access.
+	lastActivity int64
+	Socket       *websocket.Conn
+	Context      *WsContext
+	writeMutex   sync.Mutex
 }
 
 // WsManager is the manager of connections
@@ -86,11 +106,62 @@ func (mgr *WsManager) RemoveConnection(conn *websocket.Conn) {
 	mutex.Unlock()
 }
 
+// RemoveUser removes the user mapping if it still points to the connection
+func (mgr *WsManager) RemoveUser(username string, conn *websocket.Conn) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if mgr.users[username] == conn {
+		delete(mgr.users, username)
+	}
+}
+
 // CloseConnection closes the connection
 func (mgr *WsManager) CloseConnection(conn *websocket.Conn) {
 	conn.Close()
 }
 
+// CloseSession closes every connection authenticated with the session of the token family.
+// The read loop of each connection then removes it from the manager.
+func (mgr *WsManager) CloseSession(family string) {
+	mutex.Lock()
+	var conns []*websocket.Conn
+	for conn, wsCon := range mgr.connections {
+		if wsCon.Context.Family == family {
+			conns = append(conns, conn)
+		}
+	}
+	mutex.Unlock()
+	for _, conn := range conns {
+		mgr.CloseConnection(conn)
+	}
+}
+
+// CloseUser closes every connection of the user
+func (mgr *WsManager) CloseUser(userID primitive.ObjectID) {
+	for _, wsCon := range mgr.UserConnections(userID) {
+		mgr.CloseConnection(wsCon.Socket)
+	}
+}
+
+// SetFamily moves the connection to the session of the token family
+func (mgr *WsManager) SetFamily(wsCon *WsConnection, family string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	wsCon.Context.Family = family
+}
+
+// IsSessionConnected returns true if a connection is authenticated with the session of the token family
+func (mgr *WsManager) IsSessionConnected(family string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, wsCon := range mgr.connections {
+		if wsCon.Context.Family == family {
+			return true
+		}
+	}
+	return false
+}
+
 // Length returns the number of active connections
 func (mgr *WsManager) Length() int {
 	mutex.Lock()
@@ -101,83 +172,127 @@ func (mgr *WsManager) Length() int {
 
 // Get gets the WsConnection with the specified conn
 func (mgr *WsManager) Get(conn *websocket.Conn) *WsConnection {
+	mutex.Lock()
+	defer mutex.Unlock()
 	return mgr.connections[conn]
 }
 
 // GetByUsername gets the WsConnection bound to the username specified
 func (mgr *WsManager) GetByUsername(username string) *WsConnection {
+	mutex.Lock()
+	defer mutex.Unlock()
 	if conn, ok := mgr.users[username]; ok {
 		return mgr.connections[conn]
 	}
 	return nil
 }
 
+// UserConnections returns the connections of the user
+func (mgr *WsManager) UserConnections(userID primitive.ObjectID) []*WsConnection {
+	mutex.Lock()
+	defer mutex.Unlock()
+	var res []*WsConnection
+	for _, wsCon := range mgr.connections {
+		if wsCon.Context.User != nil && wsCon.Context.User.ID == userID {
+			res = append(res, wsCon)
+		}
+	}
+	return res
+}
+
 // WriteConnection writes the JSON serialized form of the data to the connection
 func (mgr *WsManager) WriteConnection(conn *WsConnection, data interface{}) {
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
 	if err := conn.Socket.WriteJSON(data); err != nil {
 		log.Errorf("%v", ErrWritingToConnection)
 	}
 }
 
-// Listen listens for connections and reads messages
+// Listen authenticates the upgrade request, then listens for messages on the connection.
+// Requests without a valid access token are rejected before the upgrade.
 func Listen(ctx echo.Context) error {
-	conn, err := upgrader.Upgrade(ctx.Response(), ctx.Request(), nil)
+	user, family, err := authenticate(ctx.Request())
+	if err == ErrSocketNotAuthenticated || err == token.ErrInvalidToken || err == token.ErrTokenRevoked || err == token.ErrInvalidTicket {
+		return ctx.JSON(http.StatusUnauthorized, SocketResponseError{Error: err.Error()})
+	}
 	if err != nil {
 		return err
 	}
 
-	conn.SetCloseHandler(func(code int, text string) error {
-		player := ServerManager().connections[conn]
-		GameManager().RemoveSearcher(player)
-		game := GameManager().FindPlayerGame(player)
-		if game != nil {
-			game.PrematureLoose(player)
-		}
-		ServerManager().RemoveConnection(conn)
-		conn.Close()
-		return nil
-	})
+	conn, err := upgrader.Upgrade(ctx.Response(), ctx.Request(), nil)
+	if err != nil {
+		return err
+	}
 
 	wsConn := &WsConnection{
 		Socket:  conn,
-		Context: &WsContext{Ready: false, User: nil},
+		Context: &WsContext{User: user, Family: family},
 	}
+	wsConn.touch()
 	ServerManager().AddConnection(wsConn)
+	ServerManager().AddUser(user.Username, conn)
+	updatePresence(user.ID, user.Username)
+	defer disconnect(wsConn)
 
 	for {
-		// Read
+		// Read. Errors are permanent: the peer left or the connection was closed by the server.
 		_, bytes, err := conn.ReadMessage()
 		if err != nil {
-			log.Errorf("%v", err)
-			_ = conn.WriteJSON(SocketResponseError{Error: err.Error()})
-
-		} else {
-			go handleRead(bytes, conn)
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Errorf("%v", err)
+			}
+			return nil
 		}
+		go handleRead(bytes, conn)
+	}
+}
+
+// disconnect removes the player from the search queue and their game, cancels their challenges and forgets the connection
+func disconnect(player *WsConnection) {
+	GameManager().RemoveSearcher(player)
+	cancelChallenges(player)
+	if game := GameManager().FindPlayerGame(player); game != nil {
+		game.Leave(player)
+	}
+	if player.Context.User != nil {
+		ServerManager().RemoveUser(player.Context.User.Username, player.Socket)
+	}
+	ServerManager().RemoveConnection(player.Socket)
+	player.Socket.Close()
+	if player.Context.User != nil {
+		updatePresence(player.Context.User.ID, player.Context.User.Username)
 	}
 }
 
 func handleRead(bytes []byte, conn *websocket.Conn) {
+	wsConnection := ServerManager().Get(conn)
+	if wsConnection == nil {
+		return
+	}
+
+	// replies go through WriteConnection, as other messages may be written to the socket at the same time
 	var msg SocketMessage
 	if err := json.Unmarshal(bytes, &msg); err != nil {
 		log.Errorf("%v", err)
-		_ = conn.WriteJSON(SocketResponseError{Error: err.Error()})
+		ServerManager().WriteConnection(wsConnection, SocketResponseError{Error: err.Error()})
 		return
 	}
 
 	target, ok := msgTypeMap[msg.Type]
 	if !ok {
-		_ = conn.WriteJSON(SocketResponseError{Error: ErrUnknownMessageType.Error()})
+		ServerManager().WriteConnection(wsConnection, SocketResponseError{Error: ErrUnknownMessageType.Error()})
 		return
 	}
-
-	// first read must be auth
-	wsConnection := ServerManager().Get(conn)
-	if !wsConnection.Context.Ready && msg.Type != MessageTypeAuth && msg.Type != MessageTypePing {
-		_ = conn.WriteJSON(SocketResponseError{Error: ErrSocketNotAuthenticated.Error()})
+	target, err := decodePayload(bytes, msg, target)
+	if err != nil {
+		ServerManager().WriteConnection(wsConnection, SocketResponseError{Error: err.Error()})
 		return
 	}
 
+	wsConnection.touch()
+	updatePresence(wsConnection.Context.User.ID, wsConnection.Context.User.Username)
+
 	// handlers
 	switch msg.Type {
 	case MessageTypePing:
@@ -191,7 +306,51 @@ func handleRead(bytes []byte, conn *websocket.Conn) {
 	case MessageTypeQuit:
 		quitMsg := target.(SocketMessageQuit)
 		handleQuitMessage(wsConnection, quitMsg)
+	case MessageTypeMediaReady:
+		readyMsg := target.(SocketMessageMediaReady)
+		handleMediaReadyMessage(wsConnection, readyMsg)
+	case MessageTypeChallenge:
+		challengeMsg := target.(SocketMessageChallenge)
+		handleChallengeMessage(wsConnection, challengeMsg)
+	case MessageTypeChallengeAccept:
+		answerMsg := target.(SocketMessageChallengeAnswer)
+		handleChallengeAcceptMessage(wsConnection, answerMsg)
+	case MessageTypeChallengeDecline:
+		answerMsg := target.(SocketMessageChallengeAnswer)
+		handleChallengeDeclineMessage(wsConnection, answerMsg)
+	}
+}
+
+// decodePayload decodes the payload of the message into a new value of the type of target.
+// The payload is read from "<type>Message", e.g. "answerMessage", or from "message",
+// which may also hold the payload as a JSON encoded string.
+func decodePayload(bytes []byte, msg SocketMessage, target interface{}) (interface{}, error) {
+	if target == nil {
+		return nil, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return nil, err
+	}
+	payload, ok := fields[msg.Type+"Message"]
+	if !ok {
+		payload = msg.Message
+	}
+	if len(payload) > 0 && payload[0] == '"' {
+		var s string
+		if err := json.Unmarshal(payload, &s); err != nil {
+			return nil, err
+		}
+		payload = json.RawMessage(s)
+	}
+
+	value := reflect.New(reflect.TypeOf(target))
+	if len(payload) > 0 && string(payload) != "null" {
+		if err := json.Unmarshal(payload, value.Interface()); err != nil {
+			return nil, ErrInvalidMessage
+		}
 	}
+	return value.Elem().Interface(), nil
 }
 
 type SocketResponseError struct {
@@ -199,6 +358,6 @@ type SocketResponseError struct {
 }
 
 type SocketMessage struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message"`
 }
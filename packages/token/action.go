@@ -0,0 +1,89 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	usedTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/usedtoken"
+	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purpose is what a one-time action token can be used for
+type Purpose string
+
+const (
+	PurposeVerifyEmail   Purpose = "verify_email"
+	PurposeResetPassword Purpose = "reset_password"
+	// PurposeMFA is the purpose of the token that carries a login from the password to the second factor
+	PurposeMFA Purpose = "mfa"
+	// PurposeOIDCLogin is the purpose of the code that carries a login from the identity provider to the app
+	PurposeOIDCLogin Purpose = "oidc_login"
+)
+
+var (
+	// ErrTokenUsed is returned when a one-time token is redeemed a second time
+	ErrTokenUsed = errors.New("token has already been used")
+)
+
+// ActionClaims are the claims of a one-time action token.
+// The subject is the id of the user the token was issued for.
+type ActionClaims struct {
+	Purpose Purpose `json:"purpose"`
+	Email   string  `json:"email,omitempty"`
+	jwt.StandardClaims
+}
+
+// UserID returns the id of the user the token was issued for
+func (c *ActionClaims) UserID() (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(c.Subject)
+}
+
+// IssueAction signs a one-time token for the purpose that expires after ttl
+func IssueAction(userID primitive.ObjectID, purpose Purpose, email string, ttl time.Duration) (string, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := &ActionClaims{
+		Purpose: purpose,
+		Email:   email,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Audience:  audienceAction,
+			Subject:   userID.Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+	return sign(claims)
+}
+
+// VerifyAction verifies the one-time token without using it up. Access tokens are rejected.
+func VerifyAction(signed string, purpose Purpose) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	t, err := jwt.ParseWithClaims(signed, claims, keyFunc)
+	if err != nil || !t.Valid || claims.Purpose != purpose || claims.Id == "" || !claims.VerifyAudience(audienceAction, true) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// RedeemAction verifies the one-time token and marks it as used.
+// A token can only be redeemed once and only for the purpose it was issued for.
+func RedeemAction(signed string, purpose Purpose) (*ActionClaims, error) {
+	claims, err := VerifyAction(signed, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := usedTokenService.MarkUsed(claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTokenUsed
+	}
+	return claims, nil
+}
@@ -0,0 +1,292 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/gommon/log"
+)
+
+// secretKeyID is the id of the key read from JWT_SECRET.
+// Tokens without a kid header are verified with it.
+const secretKeyID = "secret"
+
+var (
+	// ErrNoSigningKey is returned when no key can sign tokens
+	ErrNoSigningKey = errors.New("no signing key configured. Set JWT_SECRET or JWT_KEYS_DIR")
+	// ErrUnsupportedKey is returned when a key file holds a key type we cannot use
+	ErrUnsupportedKey = errors.New("unsupported key type")
+
+	ringMutex sync.RWMutex
+	ring      *keyring
+)
+
+// Key is a key of the keyring.
+// Keys loaded from a public key file can only verify tokens.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign returns true if the key holds the private part needed to sign
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+type keyring struct {
+	keys    map[string]*Key
+	signing *Key
+}
+
+// JWK is a public key in the JSON Web Key format
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeys (re)loads the keyring from the environment.
+//
+// JWT_SECRET is an HS256 key with the id "secret".
+// JWT_KEYS_DIR is a directory of keys named after their kid: "<kid>.pem" holds an RSA (RS256)
+// or Ed25519 (EdDSA) private key, or a public key that only verifies, and "<kid>.key" holds an HS256 secret.
+// JWT_SIGNING_KID selects the key that signs new tokens. It defaults to the key of the directory
+// that can sign and has the last kid in lexical order, so dated kids rotate by adding a file.
+//
+// The current keyring is kept if the new one cannot be loaded.
+func LoadKeys() error {
+	r := &keyring{keys: make(map[string]*Key)}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		r.keys[secretKeyID] = hmacKey(secretKeyID, []byte(secret))
+	}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			ext := filepath.Ext(f.Name())
+			if f.IsDir() || (ext != ".pem" && ext != ".key") {
+				continue
+			}
+			kid := strings.TrimSuffix(f.Name(), ext)
+			b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+			if err != nil {
+				return err
+			}
+			var key *Key
+			if ext == ".key" {
+				key = hmacKey(kid, []byte(strings.TrimSpace(string(b))))
+			} else if key, err = pemKey(kid, b); err != nil {
+				return fmt.Errorf("%s: %v", f.Name(), err)
+			}
+			r.keys[kid] = key
+		}
+	}
+
+	kid := os.Getenv("JWT_SIGNING_KID")
+	if kid == "" {
+		kid = defaultSigningKid(r.keys)
+	}
+	signing, ok := r.keys[kid]
+	if !ok || !signing.CanSign() {
+		return ErrNoSigningKey
+	}
+	r.signing = signing
+
+	ringMutex.Lock()
+	ring = r
+	ringMutex.Unlock()
+	return nil
+}
+
+// WatchKeys reloads the keyring every time the process receives SIGHUP.
+// New keys can be added and old ones retired without restarting the server.
+func WatchKeys() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if err := LoadKeys(); err != nil {
+			log.Errorf("reloading jwt keys: %v", err)
+			continue
+		}
+		log.Infof("jwt keys reloaded. Signing with %s", SigningKeyID())
+	}
+}
+
+// SigningKeyID returns the kid of the key that signs new tokens
+func SigningKeyID() string {
+	r, err := keys()
+	if err != nil {
+		return ""
+	}
+	return r.signing.ID
+}
+
+// PublicKeys returns the public keys of the keyring. HS256 secrets are never published.
+func PublicKeys() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	r, err := keys()
+	if err != nil {
+		return set
+	}
+	var kids []string
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		key := r.keys[kid]
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// sign signs the claims with the signing key and sets the kid header
+func sign(claims jwt.Claims) (string, error) {
+	r, err := keys()
+	if err != nil {
+		return "", err
+	}
+	t := jwt.NewWithClaims(r.signing.Method, claims)
+	t.Header["kid"] = r.signing.ID
+	return t.SignedString(r.signing.signKey)
+}
+
+// keyFunc returns the key that verifies the token.
+// The algorithm of the token must be the one of its key.
+func keyFunc(t *jwt.Token) (interface{}, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = secretKeyID
+	}
+	key, ok := r.keys[kid]
+	if !ok || t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.verifyKey, nil
+}
+
+func keys() (*keyring, error) {
+	ringMutex.RLock()
+	r := ring
+	ringMutex.RUnlock()
+	if r != nil {
+		return r, nil
+	}
+	if err := LoadKeys(); err != nil {
+		return nil, err
+	}
+	ringMutex.RLock()
+	defer ringMutex.RUnlock()
+	return ring, nil
+}
+
+func defaultSigningKid(keys map[string]*Key) string {
+	kid := ""
+	for id, key := range keys {
+		if id != secretKeyID && key.CanSign() && id > kid {
+			kid = id
+		}
+	}
+	if kid == "" {
+		return secretKeyID
+	}
+	return kid
+}
+
+func hmacKey(kid string, secret []byte) *Key {
+	return &Key{
+		ID:        kid,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+func pemKey(kid string, b []byte) (*Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.signKey = parsed
+		parsed = signer.Public()
+	}
+	switch parsed.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, parsed)
+	}
+	key.verifyKey = parsed
+	return key, nil
+}
@@ -0,0 +1,68 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	revokedTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/revokedtoken"
+	wsTicketService "github.com/acha-bill/quizzer_backend/packages/dblayer/wsticket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TicketTTL is how long a socket ticket can be redeemed for
+const TicketTTL = 30 * time.Second
+
+var (
+	// ErrInvalidTicket is returned when a ticket is unknown, expired, already used or used from another origin
+	ErrInvalidTicket = errors.New("invalid ticket")
+)
+
+// IssueTicket returns a single-use ticket that opens a socket for the session of the access token.
+// The ticket can only be redeemed from origin.
+func IssueTicket(claims *common.JWTCustomClaims, origin string) (string, time.Time, error) {
+	userID, err := primitive.ObjectIDFromHex(claims.Id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	ticket, err := randomString(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(TicketTTL)
+	err = wsTicketService.Create(models.WsTicket{
+		ID:         primitive.NewObjectID(),
+		TicketHash: hash(ticket),
+		UserID:     userID,
+		Family:     claims.Family,
+		Origin:     origin,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return ticket, expiresAt, nil
+}
+
+// RedeemTicket uses up the ticket and returns it.
+// The ticket must be redeemed from the origin it was issued to and its session must not have been revoked.
+func RedeemTicket(ticket string, origin string) (*models.WsTicket, error) {
+	t, err := wsTicketService.Consume(hash(ticket))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.Origin != origin {
+		return nil, ErrInvalidTicket
+	}
+	revoked, err := revokedTokenService.IsRevoked(t.Family)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return t, nil
+}
@@ -0,0 +1,269 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	refreshTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/refreshtoken"
+	revokedTokenService "github.com/acha-bill/quizzer_backend/packages/dblayer/revokedtoken"
+	sessionService "github.com/acha-bill/quizzer_backend/packages/dblayer/session"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// AccessTokenTTL is how long an access token is valid for
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a refresh token is valid for
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// sessionTouchInterval is how often the last seen time of a session is written
+	sessionTouchInterval = time.Minute
+
+	// audienceAccess and audienceAction tell access tokens and one-time action tokens apart,
+	// as both are signed with the same keys
+	audienceAccess = "access"
+	audienceAction = "action"
+)
+
+var (
+	// ErrInvalidToken is returned when a token cannot be parsed or verified
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenRevoked is returned when a token has been revoked
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token that was already rotated is used again
+	ErrRefreshTokenReused = errors.New("refresh token reused. All sessions of this login have been revoked")
+	// ErrAccountSuspended is returned when tokens are requested for a banned or suspended account
+	ErrAccountSuspended = errors.New("account is suspended")
+)
+
+// Pair is an access token together with the refresh token used to renew it
+type Pair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Client describes the device a token is issued to
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// Issue starts a new token family for the user and returns its first token pair.
+// The family is recorded as a session of the client.
+// mfa records whether the user has passed a second authentication factor.
+func Issue(u *models.User, client Client, mfa ...bool) (*Pair, error) {
+	family, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := issue(u, family, len(mfa) > 0 && mfa[0])
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_, err = sessionService.Create(models.Session{
+		ID:         primitive.NewObjectID(),
+		Family:     family,
+		UserID:     u.ID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh rotates the refresh token and returns a new token pair in the same family.
+// If the refresh token has already been used, the whole family is revoked.
+func Refresh(refreshToken string, client Client) (*Pair, error) {
+	stored, err := refreshTokenService.FindByHash(hash(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if stored == nil || stored.RevokedAt != nil || now.After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	ok, err := refreshTokenService.MarkUsed(stored.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := RevokeFamily(stored.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	u := userService.FindById(stored.UserID.Hex())
+	if u.ID.IsZero() {
+		return nil, ErrInvalidRefreshToken
+	}
+	pair, err := issue(u, stored.Family, stored.MFA)
+	if err != nil {
+		return nil, err
+	}
+	if err := sessionService.Refreshed(stored.Family, client.UserAgent, client.IP, now.Add(RefreshTokenTTL)); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Parse verifies the signed access token and checks that it has not been revoked.
+// Other kinds of tokens are rejected.
+func Parse(signed string) (*common.JWTCustomClaims, error) {
+	claims := &common.JWTCustomClaims{}
+	t, err := jwt.ParseWithClaims(signed, claims, keyFunc)
+	if err != nil || !t.Valid || claims.Id == "" || !claims.VerifyAudience(audienceAccess, true) {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := IsRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// IsRevoked returns true if the access token or its family has been revoked
+func IsRevoked(claims *common.JWTCustomClaims) (bool, error) {
+	return revokedTokenService.IsRevoked(claims.StandardClaims.Id, claims.Family)
+}
+
+// Revoke revokes the access token and its whole family
+func Revoke(claims *common.JWTCustomClaims) error {
+	if err := revokedTokenService.Revoke(claims.StandardClaims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return err
+	}
+	return RevokeFamily(claims.Family)
+}
+
+// RevokeFamily revokes every refresh token of the family and the access tokens issued with them,
+// ending the session.
+func RevokeFamily(family string) error {
+	now := time.Now()
+	if err := refreshTokenService.RevokeFamily(family, now); err != nil {
+		return err
+	}
+	if err := sessionService.Revoke(family, now); err != nil {
+		return err
+	}
+	return revokedTokenService.Revoke(family, now.Add(AccessTokenTTL))
+}
+
+// RevokeUser revokes every token family of the user, logging them out everywhere.
+func RevokeUser(userID primitive.ObjectID) error {
+	families, err := refreshTokenService.ActiveFamilies(userID)
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := RevokeFamily(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget revokes every token family of the user, then deletes their tokens and sessions.
+// It is used when the account is deleted.
+func Forget(userID primitive.ObjectID) error {
+	if err := RevokeUser(userID); err != nil {
+		return err
+	}
+	if err := refreshTokenService.DeleteByUser(userID); err != nil {
+		return err
+	}
+	return sessionService.DeleteByUser(userID)
+}
+
+// Touch records that the session of the token family has just been used
+func Touch(family string) error {
+	return sessionService.Touch(family, sessionTouchInterval)
+}
+
+func issue(u *models.User, family string, mfa bool) (*Pair, error) {
+	now := time.Now()
+	if u.IsSuspended(now) {
+		return nil, ErrAccountSuspended
+	}
+	if err := userService.Touch(u.ID); err != nil {
+		return nil, err
+	}
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(AccessTokenTTL)
+	claims := &common.JWTCustomClaims{
+		Username: u.Username,
+		Role:     u.Role,
+		Id:       u.ID.Hex(),
+		Family:   family,
+		MFA:      mfa,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Audience:  audienceAccess,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+	access, err := sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	_, err = refreshTokenService.Create(models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		Family:    family,
+		UserID:    u.ID,
+		TokenHash: hash(refresh),
+		MFA:       mfa,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,362 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/audit"
+	auditLogService "github.com/acha-bill/quizzer_backend/packages/dblayer/auditlog"
+	sessionService "github.com/acha-bill/quizzer_backend/packages/dblayer/session"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/acha-bill/quizzer_backend/plugins"
+	"github.com/acha-bill/quizzer_backend/plugins/auth"
+	"github.com/acha-bill/quizzer_backend/plugins/moderation"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// PluginName defines the name of the plugin
+	PluginName = "admin"
+
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var (
+	plugin *Admin
+	once   sync.Once
+	// ErrUserNotFound is returned when the user does not exist
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// Admin structure
+type Admin struct {
+	name     string
+	handlers []*plugins.PluginHandler
+}
+
+// AddHandler Method definition from interface
+func (plugin *Admin) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
+	pluginHandler := &plugins.PluginHandler{
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionUserManage,
+	}
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
+	}
+	plugin.handlers = append(plugin.handlers, pluginHandler)
+}
+
+// Handlers Method definition from interface
+func (plugin *Admin) Handlers() []*plugins.PluginHandler {
+	return plugin.handlers
+}
+
+// Name defines the name of the plugin
+func (plugin *Admin) Name() string {
+	return plugin.name
+}
+
+// NewPlugin returns the new plugin
+func NewPlugin() *Admin {
+	plugin := &Admin{
+		name: PluginName,
+	}
+	return plugin
+}
+
+// Plugin returns an instance of the plugin
+func Plugin() *Admin {
+	once.Do(func() {
+		plugin = NewPlugin()
+	})
+	return plugin
+}
+
+func init() {
+	admin := Plugin()
+	admin.AddHandler(http.MethodGet, "/users", listUsers)
+	admin.AddHandler(http.MethodGet, "/users/:username", getUser)
+	admin.AddHandler(http.MethodPost, "/users/:username/suspend", suspendUser)
+	admin.AddHandler(http.MethodPost, "/users/:username/ban", banUser)
+	admin.AddHandler(http.MethodPost, "/users/:username/unban", unbanUser)
+	admin.AddHandler(http.MethodPost, "/users/:username/password", resetPassword)
+	admin.AddHandler(http.MethodPost, "/users/:username/logout", logoutUser)
+	admin.AddHandler(http.MethodPut, "/users/:username/role", setRole, rbac.PermissionRoleManage)
+	admin.AddHandler(http.MethodGet, "/roles", listRoles, rbac.PermissionRoleManage)
+	admin.AddHandler(http.MethodGet, "/audit", listAudit)
+
+	// roles and suspensions used to be managed through the auth and moderation plugins,
+	// whose routes are kept for existing clients
+	auth.Plugin().AddHandler(http.MethodGet, "/roles", listRoles, rbac.PermissionRoleManage)
+	auth.Plugin().AddHandler(http.MethodPut, "/role/:username", setRole, rbac.PermissionRoleManage)
+	moderation.Plugin().AddHandler(http.MethodPost, "/users/:username/suspend", suspendUser, rbac.PermissionUserManage)
+	moderation.Plugin().AddHandler(http.MethodPost, "/users/:username/ban", banUser, rbac.PermissionUserManage)
+	moderation.Plugin().AddHandler(http.MethodPost, "/users/:username/unban", unbanUser, rbac.PermissionUserManage)
+}
+
+// @Summary list and search users
+// @Description q matches part of the username, display name or email, ignoring case.
+// @Produce  application/json
+// @Router /admin/users [get]
+// @Tags Admin
+// @Param q query string false "search"
+// @Param role query string false "role"
+// @Param page query int false "page, starting at 1"
+// @Param limit query int false "users per page, at most 100"
+// @Success 200 {object} ListUsersResponse
+func listUsers(ctx echo.Context) error {
+	role := rbac.Role(ctx.QueryParam("role"))
+	if role != "" && !rbac.IsValid(role) {
+		return ctx.JSON(http.StatusBadRequest, ListUsersResponse{
+			Error: ErrUnknownRole.Error(),
+		})
+	}
+	page, limit, err := common.Pagination(ctx, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ListUsersResponse{
+			Error: err.Error(),
+		})
+	}
+
+	users, total, err := userService.Search(ctx.QueryParam("q"), role, (page-1)*limit, limit)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListUsersResponse{
+			Error: err.Error(),
+		})
+	}
+	res := ListUsersResponse{
+		Users: []UserResponse{},
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	}
+	for _, u := range users {
+		res.Users = append(res.Users, newUserResponse(u))
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary get the details of a user
+// @Produce  application/json
+// @Router /admin/users/{username} [get]
+// @Tags Admin
+// @Param username path string true "username"
+// @Success 200 {object} UserDetailsResponse
+// @Failure 404 {object} UserDetailsResponse
+func getUser(ctx echo.Context) error {
+	u := userService.FindByUsername(ctx.Param("username"))
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, UserDetailsResponse{
+			UserResponse: UserResponse{Error: ErrUserNotFound.Error()},
+		})
+	}
+	sessions, err := sessionService.FindActiveByUser(u.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, UserDetailsResponse{
+			UserResponse: UserResponse{Error: err.Error()},
+		})
+	}
+
+	res := UserDetailsResponse{
+		UserResponse:     newUserResponse(u),
+		TwoFactorEnabled: u.TOTPEnabled,
+		FailedLogins:     u.FailedLogins,
+		Presence:         socketserver.UserPresence(u.ID),
+		Sessions:         []SessionResponse{},
+	}
+	if u.LockedUntil.After(time.Now()) {
+		res.LockedUntil = &u.LockedUntil
+	}
+	for _, identity := range u.Identities {
+		res.Identities = append(res.Identities, identity.Issuer)
+	}
+	for _, s := range sessions {
+		res.Sessions = append(res.Sessions, SessionResponse{
+			ID:         s.ID.Hex(),
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+		})
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary log a user out everywhere
+// @Description Revokes every session of the user and closes their sockets.
+// @Produce  application/json
+// @Router /admin/users/{username}/logout [post]
+// @Tags Admin
+// @Param username path string true "username"
+// @Success 200 {object} UserResponse
+// @Failure 404 {object} UserResponse
+func logoutUser(ctx echo.Context) error {
+	u := userService.FindByUsername(ctx.Param("username"))
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, UserResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if err := logoutEverywhere(u); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, UserResponse{
+			Error: err.Error(),
+		})
+	}
+	audit.Record(ctx, audit.ActionLogout, u, "")
+	return ctx.JSON(http.StatusOK, newUserResponse(u))
+}
+
+// @Summary list the audit log, most recent first
+// @Description username limits the entries to those where the user is the actor or the target.
+// @Produce  application/json
+// @Router /admin/audit [get]
+// @Tags Admin
+// @Param username query string false "username"
+// @Param page query int false "page, starting at 1"
+// @Param limit query int false "entries per page, at most 100"
+// @Success 200 {object} ListAuditResponse
+func listAudit(ctx echo.Context) error {
+	page, limit, err := common.Pagination(ctx, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ListAuditResponse{
+			Error: err.Error(),
+		})
+	}
+
+	var entries []*models.AuditEntry
+	if username := ctx.QueryParam("username"); username != "" {
+		u := userService.FindByUsername(username)
+		if u.ID.IsZero() {
+			return ctx.JSON(http.StatusNotFound, ListAuditResponse{
+				Error: ErrUserNotFound.Error(),
+			})
+		}
+		entries, err = auditLogService.FindByUser(u.ID, (page-1)*limit, limit)
+	} else {
+		entries, err = auditLogService.Find(bson.D{}, (page-1)*limit, limit)
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListAuditResponse{
+			Error: err.Error(),
+		})
+	}
+
+	res := ListAuditResponse{
+		Entries: []AuditEntryResponse{},
+		Page:    page,
+		Limit:   limit,
+	}
+	for _, e := range entries {
+		res.Entries = append(res.Entries, AuditEntryResponse{
+			Actor:     e.ActorUsername,
+			Action:    e.Action,
+			Target:    e.TargetUsername,
+			Details:   e.Details,
+			IP:        e.IP,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// logoutEverywhere revokes every session of the user and closes their sockets
+func logoutEverywhere(u *models.User) error {
+	if err := token.RevokeUser(u.ID); err != nil {
+		return err
+	}
+	socketserver.ServerManager().CloseUser(u.ID)
+	return nil
+}
+
+func newUserResponse(u *models.User) UserResponse {
+	res := UserResponse{
+		Username:      u.Username,
+		DisplayName:   u.DisplayName,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Role:          u.Role,
+		IsGuest:       u.IsGuest,
+		Banned:        u.Banned,
+		SuspendReason: u.SuspendReason,
+		CreatedAt:     u.CreatedAt,
+		LastSeenAt:    u.LastSeenAt,
+	}
+	if u.SuspendedUntil.After(time.Now()) {
+		res.SuspendedUntil = &u.SuspendedUntil
+	}
+	return res
+}
+
+// UserResponse represents a user as seen by staff
+type UserResponse struct {
+	Error          string     `json:"error,omitempty"`
+	Username       string     `json:"username,omitempty"`
+	DisplayName    string     `json:"displayName,omitempty"`
+	Email          string     `json:"email,omitempty"`
+	EmailVerified  bool       `json:"emailVerified,omitempty"`
+	Role           rbac.Role  `json:"role,omitempty"`
+	IsGuest        bool       `json:"isGuest,omitempty"`
+	Banned         bool       `json:"banned"`
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	SuspendReason  string     `json:"suspendReason,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	LastSeenAt     time.Time  `json:"lastSeenAt"`
+}
+
+// SessionResponse represents an active session of a user
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// UserDetailsResponse represents the Response object for GetUser
+type UserDetailsResponse struct {
+	UserResponse
+	TwoFactorEnabled bool                  `json:"twoFactorEnabled"`
+	FailedLogins     int                   `json:"failedLogins"`
+	LockedUntil      *time.Time            `json:"lockedUntil,omitempty"`
+	Identities       []string              `json:"identities,omitempty"`
+	Presence         socketserver.Presence `json:"presence"`
+	Sessions         []SessionResponse     `json:"sessions"`
+}
+
+// ListUsersResponse represents the Response object for ListUsers
+type ListUsersResponse struct {
+	Error string         `json:"error,omitempty"`
+	Users []UserResponse `json:"users"`
+	Page  int64          `json:"page,omitempty"`
+	Limit int64          `json:"limit,omitempty"`
+	Total int64          `json:"total"`
+}
+
+// AuditEntryResponse represents an entry of the audit log
+type AuditEntryResponse struct {
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details,omitempty"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ListAuditResponse represents the Response object for ListAudit
+type ListAuditResponse struct {
+	Error   string               `json:"error,omitempty"`
+	Entries []AuditEntryResponse `json:"entries"`
+	Page    int64                `json:"page,omitempty"`
+	Limit   int64                `json:"limit,omitempty"`
+}
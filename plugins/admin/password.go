@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/acha-bill/quizzer_backend/packages/audit"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// @Summary reset the password of a user
+// @Description Replaces the password with a random temporary one that is returned once,
+// @Description and logs the user out everywhere. Share it with the user over a trusted channel.
+// @Produce  application/json
+// @Router /admin/users/{username}/password [post]
+// @Tags Admin
+// @Param username path string true "username"
+// @Success 200 {object} ResetPasswordResponse
+// @Failure 403 {object} ResetPasswordResponse
+// @Failure 404 {object} ResetPasswordResponse
+func resetPassword(ctx echo.Context) error {
+	u, status, err := findTarget(ctx)
+	if err != nil {
+		return ctx.JSON(status, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.SetPassword(u.ID, string(hashedPassword)); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := logoutEverywhere(u); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	audit.Record(ctx, audit.ActionResetPassword, u, "")
+	return ctx.JSON(http.StatusOK, ResetPasswordResponse{
+		Username:          u.Username,
+		TemporaryPassword: password,
+	})
+}
+
+// ResetPasswordResponse represents the Response object for ResetPassword
+type ResetPasswordResponse struct {
+	Error             string `json:"error,omitempty"`
+	Username          string `json:"username,omitempty"`
+	TemporaryPassword string `json:"temporaryPassword,omitempty"`
+}
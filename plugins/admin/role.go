@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/acha-bill/quizzer_backend/packages/audit"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// ErrUnknownRole is returned when the requested role does not exist
+	ErrUnknownRole = errors.New("unknown role")
+	// ErrLastAdmin is returned when removing the admin role from the only admin
+	ErrLastAdmin = errors.New("cannot remove the role of the last admin")
+)
+
+// @Summary list the roles and the permissions they grant
+// @Produce  application/json
+// @Router /admin/roles [get]
+// @Router /auth/roles [get]
+// @Tags Admin
+// @Success 200 {object} ListRolesResponse
+func listRoles(ctx echo.Context) error {
+	var roles []RoleResponse
+	for _, role := range rbac.Roles() {
+		roles = append(roles, RoleResponse{
+			Name:        role,
+			Permissions: rbac.Permissions(role),
+		})
+	}
+	return ctx.JSON(http.StatusOK, ListRolesResponse{
+		Roles: roles,
+	})
+}
+
+// @Summary change the role of a user
+// @Description The new role is used in the access tokens issued after the change.
+// @Description A user who loses permissions is logged out everywhere, so that their tokens stop granting them.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /admin/users/{username}/role [put]
+// @Router /auth/role/{username} [put]
+// @Tags Admin
+// @Param username path string true "username"
+// @Param role body SetRoleRequest true "role"
+// @Success 200 {object} SetRoleResponse
+func setRole(ctx echo.Context) error {
+	var req SetRoleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, SetRoleResponse{
+			Error: err.Error(),
+		})
+	}
+	if !rbac.IsValid(req.Role) {
+		return ctx.JSON(http.StatusBadRequest, SetRoleResponse{
+			Error: ErrUnknownRole.Error(),
+		})
+	}
+
+	u := userService.FindByUsername(ctx.Param("username"))
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, SetRoleResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+
+	if u.Role == rbac.RoleAdmin && req.Role != rbac.RoleAdmin {
+		admins, err := userService.CountByRole(rbac.RoleAdmin)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, SetRoleResponse{
+				Error: err.Error(),
+			})
+		}
+		if admins <= 1 {
+			return ctx.JSON(http.StatusConflict, SetRoleResponse{
+				Error: ErrLastAdmin.Error(),
+			})
+		}
+	}
+
+	if err := userService.SetRole(u.ID, req.Role); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, SetRoleResponse{
+			Error: err.Error(),
+		})
+	}
+	if isDemotion(u.Role, req.Role) {
+		if err := logoutEverywhere(u); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, SetRoleResponse{
+				Error: err.Error(),
+			})
+		}
+	}
+	audit.Record(ctx, audit.ActionSetRole, u, "%s to %s", u.Role, req.Role)
+
+	return ctx.JSON(http.StatusOK, SetRoleResponse{
+		Username: u.Username,
+		Role:     req.Role,
+	})
+}
+
+// isDemotion returns true if the new role lacks a permission of the old one
+func isDemotion(from rbac.Role, to rbac.Role) bool {
+	for _, permission := range rbac.Permissions(from) {
+		if !rbac.Can(to, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleResponse represents a role and its permissions
+type RoleResponse struct {
+	Name        rbac.Role         `json:"name"`
+	Permissions []rbac.Permission `json:"permissions"`
+}
+
+// ListRolesResponse represents the Response object for ListRoles
+type ListRolesResponse struct {
+	Error string         `json:"error,omitempty"`
+	Roles []RoleResponse `json:"roles"`
+}
+
+// SetRoleRequest represents the Request object for SetRole
+type SetRoleRequest struct {
+	Role rbac.Role `json:"role"`
+}
+
+// SetRoleResponse represents the Response object for SetRole
+type SetRoleResponse struct {
+	Error    string    `json:"error,omitempty"`
+	Username string    `json:"username,omitempty"`
+	Role     rbac.Role `json:"role,omitempty"`
+}
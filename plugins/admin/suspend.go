@@ -0,0 +1,181 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/audit"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// ErrProtectedUser is returned when acting on a user that can manage users, including oneself
+	ErrProtectedUser = errors.New("users that can manage users are protected. Change their role first")
+	// ErrInvalidSuspension is returned when the end of a suspension is not in the future
+	ErrInvalidSuspension = errors.New("suspension must end in the future")
+)
+
+// @Summary suspend a user until a time
+// @Description The user is logged out everywhere and their sockets are closed.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /admin/users/{username}/suspend [post]
+// @Router /moderation/users/{username}/suspend [post]
+// @Tags Admin
+// @Param username path string true "username"
+// @Param suspend body SuspendRequest true "suspend"
+// @Success 200 {object} AccountStatusResponse
+// @Failure 403 {object} AccountStatusResponse
+// @Failure 404 {object} AccountStatusResponse
+func suspendUser(ctx echo.Context) error {
+	var req SuspendRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	if !req.Until.After(time.Now()) {
+		return ctx.JSON(http.StatusBadRequest, AccountStatusResponse{
+			Error: ErrInvalidSuspension.Error(),
+		})
+	}
+
+	u, status, err := findTarget(ctx)
+	if err != nil {
+		return ctx.JSON(status, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.Suspend(u.ID, req.Until, req.Reason); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := logoutEverywhere(u); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	u.SuspendedUntil = req.Until
+	u.SuspendReason = req.Reason
+	audit.Record(ctx, audit.ActionSuspend, u, "until %s: %s", req.Until.Format(time.RFC3339), req.Reason)
+	return ctx.JSON(http.StatusOK, newAccountStatusResponse(u))
+}
+
+// @Summary ban a user
+// @Description The user is logged out everywhere and their sockets are closed.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /admin/users/{username}/ban [post]
+// @Router /moderation/users/{username}/ban [post]
+// @Tags Admin
+// @Param username path string true "username"
+// @Param ban body BanRequest true "ban"
+// @Success 200 {object} AccountStatusResponse
+// @Failure 403 {object} AccountStatusResponse
+// @Failure 404 {object} AccountStatusResponse
+func banUser(ctx echo.Context) error {
+	var req BanRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u, status, err := findTarget(ctx)
+	if err != nil {
+		return ctx.JSON(status, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.Ban(u.ID, req.Reason); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := logoutEverywhere(u); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	u.Banned = true
+	u.SuspendReason = req.Reason
+	audit.Record(ctx, audit.ActionBan, u, "%s", req.Reason)
+	return ctx.JSON(http.StatusOK, newAccountStatusResponse(u))
+}
+
+// @Summary lift the ban or suspension of a user
+// @Produce  application/json
+// @Router /admin/users/{username}/unban [post]
+// @Router /moderation/users/{username}/unban [post]
+// @Tags Admin
+// @Param username path string true "username"
+// @Success 200 {object} AccountStatusResponse
+// @Failure 404 {object} AccountStatusResponse
+func unbanUser(ctx echo.Context) error {
+	u := userService.FindByUsername(ctx.Param("username"))
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, AccountStatusResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if err := userService.Unban(u.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, AccountStatusResponse{
+			Error: err.Error(),
+		})
+	}
+	u.Banned = false
+	u.SuspendedUntil = time.Time{}
+	u.SuspendReason = ""
+	audit.Record(ctx, audit.ActionUnban, u, "")
+	return ctx.JSON(http.StatusOK, newAccountStatusResponse(u))
+}
+
+// findTarget finds the user of the username path parameter.
+// Users that can manage users are protected so that staff cannot lock each other out.
+func findTarget(ctx echo.Context) (*models.User, int, error) {
+	u := userService.FindByUsername(ctx.Param("username"))
+	if u.ID.IsZero() {
+		return nil, http.StatusNotFound, ErrUserNotFound
+	}
+	if rbac.Can(u.Role, rbac.PermissionUserManage) {
+		return nil, http.StatusForbidden, ErrProtectedUser
+	}
+	return u, http.StatusOK, nil
+}
+
+func newAccountStatusResponse(u *models.User) AccountStatusResponse {
+	res := AccountStatusResponse{
+		Username: u.Username,
+		Banned:   u.Banned,
+		Reason:   u.SuspendReason,
+	}
+	if u.SuspendedUntil.After(time.Now()) {
+		res.SuspendedUntil = &u.SuspendedUntil
+	}
+	return res
+}
+
+// SuspendRequest represents the Request object for SuspendUser
+type SuspendRequest struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// BanRequest represents the Request object for BanUser
+type BanRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AccountStatusResponse represents whether a user is banned or suspended
+type AccountStatusResponse struct {
+	Error          string     `json:"error,omitempty"`
+	Username       string     `json:"username,omitempty"`
+	Banned         bool       `json:"banned"`
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+}
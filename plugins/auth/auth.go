@@ -1,17 +1,23 @@
 package auth
 
 import (
+	"errors"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/acha-bill/quizzer_backend/common"
 	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/audit"
 	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/packages/token"
 	"github.com/acha-bill/quizzer_backend/plugins"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
@@ -25,6 +31,16 @@ const (
 var (
 	plugin *Auth
 	once   sync.Once
+	// ErrInvalidCredentials is returned when the username or password is wrong
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrTooManyAttempts is returned when logins are blocked after too many failures
+	ErrTooManyAttempts = errors.New("too many failed login attempts. Try again later")
+	// ErrUserNotFound is returned when the user does not exist
+	ErrUserNotFound = errors.New("user not found")
+
+	// dummyHash is compared when the user does not exist, so that the time of a login
+	// does not tell whether the username exists
+	dummyHash = []byte("$2a$10$8SZCgv0yG6k3tRE07hQAq.Cc.KcCirjJ/VRAnglfrAqfKxQNpV/m2")
 )
 
 // Auth structure
@@ -34,15 +50,15 @@ type Auth struct {
 }
 
 // AddHandler Method definition from interface
-func (plugin *Auth) AddHandler(method string, path string, handler func(echo.Context) error, authLevel ...plugins.AuthLevel) {
+func (plugin *Auth) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
 	pluginHandler := &plugins.PluginHandler{
-		Path:      path,
-		Handler:   handler,
-		Method:    method,
-		AuthLevel: plugins.AuthLevelUser,
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionAuthenticated,
 	}
-	if len(authLevel) > 0 {
-		pluginHandler.AuthLevel = authLevel[0]
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
 	}
 	plugin.handlers = append(plugin.handlers, pluginHandler)
 }
@@ -75,8 +91,30 @@ func Plugin() *Auth {
 
 func init() {
 	auth := Plugin()
-	auth.AddHandler(http.MethodPost, "/login", login, plugins.AuthLevelNone)
-	auth.AddHandler(http.MethodPost, "/register", register, plugins.AuthLevelNone)
+	auth.AddHandler(http.MethodPost, "/login", login, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/register", register, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/refresh", refresh, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/logout", logout)
+	auth.AddHandler(http.MethodGet, "/sessions", listSessions)
+	auth.AddHandler(http.MethodDelete, "/sessions", revokeSessions)
+	auth.AddHandler(http.MethodDelete, "/sessions/:id", revokeSession)
+	auth.AddHandler(http.MethodPost, "/ws-ticket", wsTicket)
+	auth.AddHandler(http.MethodPost, "/2fa/verify", verifyTwoFactor, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/2fa/enroll", enrollTwoFactor)
+	auth.AddHandler(http.MethodPost, "/2fa/confirm", confirmTwoFactor)
+	auth.AddHandler(http.MethodPost, "/2fa/disable", disableTwoFactor)
+	auth.AddHandler(http.MethodPost, "/2fa/recovery-codes", regenerateRecoveryCodes)
+	auth.AddHandler(http.MethodPost, "/guest", guest, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/guest/claim", claimGuest)
+	auth.AddHandler(http.MethodGet, "/oidc/login", oidcLogin, rbac.PermissionNone)
+	auth.AddHandler(http.MethodGet, "/oidc/callback", oidcCallback, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/oidc/token", oidcToken, rbac.PermissionNone)
+	auth.AddHandler(http.MethodGet, "/lockout/:username", getLockout, rbac.PermissionUserManage)
+	auth.AddHandler(http.MethodDelete, "/lockout/:username", clearLockout, rbac.PermissionUserManage)
+	auth.AddHandler(http.MethodPut, "/email", setEmail)
+	auth.AddHandler(http.MethodPost, "/email/verify", verifyEmail, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/forgot", forgotPassword, rbac.PermissionNone)
+	auth.AddHandler(http.MethodPost, "/reset", resetPassword, rbac.PermissionNone)
 }
 
 ///// handlers
@@ -84,6 +122,9 @@ func init() {
 // @Accept  application/json
 // @Produce  application/json
 // @Success 200 {object} LoginResponse
+// @Failure 401 {object} LoginResponse
+// @Failure 403 {object} LoginResponse
+// @Failure 429 {object} LoginResponse
 // @Router /auth/login [post]
 // @Tags Auth
 // @Param login body LoginRequest true "login"
@@ -95,6 +136,18 @@ func login(ctx echo.Context) error {
 		})
 	}
 
+	now := time.Now()
+	ip := ctx.RealIP()
+	lockedUntil, err := ipLockedUntil(ip, now)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if !lockedUntil.IsZero() {
+		return tooManyAttempts(ctx, lockedUntil, now)
+	}
+
 	filter := bson.D{primitive.E{Key: "username", Value: req.Username}}
 	users, err := userService.Find(filter)
 	if err != nil {
@@ -103,29 +156,122 @@ func login(ctx echo.Context) error {
 		})
 	}
 	if len(users) == 0 {
-		return ctx.JSON(http.StatusBadRequest, LoginResponse{
-			Error: "user not found",
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		lockedUntil, err := recordIPFailure(ip, now)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+		if !lockedUntil.IsZero() {
+			return tooManyAttempts(ctx, lockedUntil, now)
+		}
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: ErrInvalidCredentials.Error(),
 		})
 	}
 	u := users[0]
+	if u.IsLocked(now) {
+		return tooManyAttempts(ctx, u.LockedUntil, now)
+	}
 
-	claims := &common.JWTCustomClaims{
-		Username: u.Username,
-		IsAdmin:  u.IsAdmin,
-		Id:       u.ID.String(),
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 72).Unix(),
-		},
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password)); err != nil {
+		userLockedUntil, err := recordUserFailure(u, now)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+		ipLockedUntil, err := recordIPFailure(ip, now)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+		if userLockedUntil.After(ipLockedUntil) {
+			ipLockedUntil = userLockedUntil
+		}
+		if !ipLockedUntil.IsZero() {
+			return tooManyAttempts(ctx, ipLockedUntil, now)
+		}
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: ErrInvalidCredentials.Error(),
+		})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if u.FailedLogins > 0 || !u.LockedUntil.IsZero() {
+		if err := userService.ClearFailedLogins(u.ID); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+	}
 
-	return ctx.JSON(http.StatusOK, LoginResponse{
-		Token: t,
+	return completeLogin(ctx, u)
+}
+
+// tooManyAttempts responds to a login that is blocked until lockedUntil
+func tooManyAttempts(ctx echo.Context, lockedUntil time.Time, now time.Time) error {
+	retryAfter := int(math.Ceil(lockedUntil.Sub(now).Seconds()))
+	ctx.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return ctx.JSON(http.StatusTooManyRequests, LoginResponse{
+		Error: ErrTooManyAttempts.Error(),
 	})
 }
 
+// @Summary exchange a refresh token for a new token pair
+// @Accept  application/json
+// @Produce  application/json
+// @Success 200 {object} LoginResponse
+// @Failure 401 {object} LoginResponse
+// @Failure 403 {object} LoginResponse
+// @Router /auth/refresh [post]
+// @Tags Auth
+// @Param refresh body RefreshRequest true "refresh"
+func refresh(ctx echo.Context) error {
+	var req RefreshRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+
+	pair, err := token.Refresh(req.RefreshToken, clientOf(ctx))
+	if err == token.ErrInvalidRefreshToken || err == token.ErrRefreshTokenReused {
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if err == token.ErrAccountSuspended {
+		return ctx.JSON(http.StatusForbidden, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, newLoginResponse(pair))
+}
+
+// @Summary logout and revoke the current tokens
+// @Produce  application/json
+// @Success 200 {object} LogoutResponse
+// @Router /auth/logout [post]
+// @Tags Auth
+func logout(ctx echo.Context) error {
+	claims := common.GetClaims(ctx)
+	if err := token.Revoke(claims); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LogoutResponse{
+			Error: err.Error(),
+		})
+	}
+	socketserver.ServerManager().CloseSession(claims.Family)
+	return ctx.JSON(http.StatusOK, LogoutResponse{})
+}
+
 // @Summary register user
 // @Accept  application/json
 // @Produce  application/json
@@ -133,6 +279,7 @@ func login(ctx echo.Context) error {
 // @Tags Auth
 // @Param register body RegisterRequest true "register"
 // @Success 201 {object} RegisterResponse
+// @Failure 409 {object} RegisterErrorResponse
 func register(ctx echo.Context) error {
 	var req RegisterRequest
 	if err := ctx.Bind(&req); err != nil {
@@ -147,6 +294,11 @@ func register(ctx echo.Context) error {
 			Error: "Empty values for username and password",
 		})
 	}
+	if isReservedUsername(req.Username) {
+		return ctx.JSON(http.StatusBadRequest, RegisterErrorResponse{
+			Error: ErrReservedUsername.Error(),
+		})
+	}
 
 	filter := bson.D{primitive.E{Key: "username", Value: req.Username}}
 	users, err := userService.Find(filter)
@@ -161,26 +313,112 @@ func register(ctx echo.Context) error {
 		})
 	}
 
+	email := ""
+	if req.Email != "" {
+		email, err = normalizeEmail(req.Email)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, RegisterErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		owner, err := userService.FindByEmail(email)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, RegisterErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		if owner != nil {
+			return ctx.JSON(http.StatusConflict, RegisterErrorResponse{
+				Error: ErrEmailTaken.Error(),
+			})
+		}
+	}
+
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	u := models.User{
 		ID:         primitive.NewObjectID(),
 		Username:   req.Username,
 		Password:   string(hashedPassword),
+		Email:      email,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 		ProfileURL: "",
-		IsAdmin:    req.IsAdmin,
+		Role:       rbac.DefaultRole,
 	}
 	created, err := userService.Create(u)
+	if err == userService.ErrEmailTaken {
+		return ctx.JSON(http.StatusConflict, RegisterErrorResponse{
+			Error: ErrEmailTaken.Error(),
+		})
+	}
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, RegisterErrorResponse{
 			Error: err.Error(),
 		})
 	}
 
+	if created.Email != "" {
+		if err := sendVerificationEmail(created); err != nil {
+			log.Errorf("sending verification email: %v", err)
+		}
+	}
+
 	return ctx.JSON(http.StatusOK, created)
 }
 
+// @Summary get the login lockout state of a user
+// @Produce  application/json
+// @Router /auth/lockout/{username} [get]
+// @Tags Auth
+// @Param username path string true "username"
+// @Success 200 {object} LockoutResponse
+func getLockout(ctx echo.Context) error {
+	u, status, err := findUserByUsername(ctx.Param("username"))
+	if err != nil {
+		return ctx.JSON(status, LockoutResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newLockoutResponse(u))
+}
+
+// @Summary clear the login lockout of a user
+// @Produce  application/json
+// @Router /auth/lockout/{username} [delete]
+// @Tags Auth
+// @Param username path string true "username"
+// @Success 200 {object} LockoutResponse
+func clearLockout(ctx echo.Context) error {
+	u, status, err := findUserByUsername(ctx.Param("username"))
+	if err != nil {
+		return ctx.JSON(status, LockoutResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.ClearFailedLogins(u.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LockoutResponse{
+			Error: err.Error(),
+		})
+	}
+	u.FailedLogins = 0
+	u.LockedUntil = time.Time{}
+	audit.Record(ctx, audit.ActionClearLockout, u, "")
+	return ctx.JSON(http.StatusOK, newLockoutResponse(u))
+}
+
+// findUserByUsername returns the user with the username and the status code to respond with on error
+func findUserByUsername(username string) (*models.User, int, error) {
+	filter := bson.D{primitive.E{Key: "username", Value: username}}
+	users, err := userService.Find(filter)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if len(users) == 0 {
+		return nil, http.StatusNotFound, ErrUserNotFound
+	}
+	return users[0], http.StatusOK, nil
+}
+
 // LoginRequest represents the Request object for Login
 type LoginRequest struct {
 	Username string `json:"username"`
@@ -189,16 +427,38 @@ type LoginRequest struct {
 
 // LoginResponse represents the Response object for Login
 type LoginResponse struct {
+	Error        string `json:"error,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	ExpiresAt    int64  `json:"expiresAt,omitempty"`
+	MFARequired  bool   `json:"mfaRequired,omitempty"`
+	MFAToken     string `json:"mfaToken,omitempty"`
+}
+
+func newLoginResponse(pair *token.Pair) LoginResponse {
+	return LoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt.Unix(),
+	}
+}
+
+// RefreshRequest represents the Request object for Refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// LogoutResponse represents the Response object for Logout
+type LogoutResponse struct {
 	Error string `json:"error,omitempty"`
-	Token string `json:"token,omitempty"`
 }
 
 // RegisterRequest represents the Request object for Register
 type RegisterRequest struct {
 	Username   string `json:"username"`
 	Password   string `json:"password"`
+	Email      string `json:"email"`
 	ProfileURL string `json:"profileURL"`
-	IsAdmin    bool   `json:"isAdmin"`
 }
 
 // RegisterErrorResponse represents the Error Response object for Register
@@ -208,3 +468,23 @@ type RegisterErrorResponse struct {
 
 // RegisterResponse represents the Response object for Register
 type RegisterResponse models.User
+
+// LockoutResponse represents the login lockout state of a user
+type LockoutResponse struct {
+	Error           string    `json:"error,omitempty"`
+	Username        string    `json:"username,omitempty"`
+	FailedLogins    int       `json:"failedLogins"`
+	LastFailedLogin time.Time `json:"lastFailedLogin"`
+	LockedUntil     time.Time `json:"lockedUntil"`
+	Locked          bool      `json:"locked"`
+}
+
+func newLockoutResponse(u *models.User) LockoutResponse {
+	return LockoutResponse{
+		Username:        u.Username,
+		FailedLogins:    u.FailedLogins,
+		LastFailedLogin: u.LastFailedLogin,
+		LockedUntil:     u.LockedUntil,
+		Locked:          u.IsLocked(time.Now()),
+	}
+}
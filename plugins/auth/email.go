@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/mailer"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// verifyEmailTTL is how long an email verification link is valid for
+	verifyEmailTTL = 48 * time.Hour
+	// resetPasswordTTL is how long a password reset link is valid for
+	resetPasswordTTL = time.Hour
+	// minPasswordLength is the minimum length of a new password
+	minPasswordLength = 8
+)
+
+var (
+	// ErrInvalidEmail is returned when the email address cannot be parsed
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrEmailTaken is returned when the email address belongs to another user
+	ErrEmailTaken = userService.ErrEmailTaken
+	// ErrPasswordTooShort is returned when the new password is too short
+	ErrPasswordTooShort = fmt.Errorf("password must have at least %d characters", minPasswordLength)
+)
+
+// @Summary set the email of the current user and send a verification link to it
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/email [put]
+// @Tags Auth
+// @Param email body SetEmailRequest true "email"
+// @Success 200 {object} EmailResponse
+// @Failure 409 {object} EmailResponse
+func setEmail(ctx echo.Context) error {
+	var req SetEmailRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, EmailResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if u.Email == email && u.EmailVerified {
+		return ctx.JSON(http.StatusOK, newEmailResponse(u))
+	}
+
+	owner, err := userService.FindByEmail(email)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+	if owner != nil && owner.ID != u.ID {
+		return ctx.JSON(http.StatusConflict, EmailResponse{
+			Error: ErrEmailTaken.Error(),
+		})
+	}
+
+	err = userService.SetEmail(u.ID, email)
+	if err == userService.ErrEmailTaken {
+		return ctx.JSON(http.StatusConflict, EmailResponse{
+			Error: ErrEmailTaken.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+	u.Email = email
+	u.EmailVerified = false
+	if err := sendVerificationEmail(u); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, newEmailResponse(u))
+}
+
+// @Summary verify an email address with the token sent to it
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/email/verify [post]
+// @Tags Auth
+// @Param verify body TokenRequest true "verify"
+// @Success 200 {object} EmailResponse
+func verifyEmail(ctx echo.Context) error {
+	var req TokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+
+	claims, err := token.RedeemAction(req.Token, token.PurposeVerifyEmail)
+	if err == token.ErrInvalidToken || err == token.ErrTokenUsed {
+		return ctx.JSON(http.StatusBadRequest, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, EmailResponse{
+			Error: token.ErrInvalidToken.Error(),
+		})
+	}
+
+	ok, err := userService.VerifyEmail(userID, claims.Email)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EmailResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, EmailResponse{
+			Error: token.ErrInvalidToken.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, EmailResponse{
+		Email:         claims.Email,
+		EmailVerified: true,
+	})
+}
+
+// @Summary send a password reset link to the verified email of a user
+// @Description The response is the same whether or not the user exists.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/forgot [post]
+// @Tags Auth
+// @Param forgot body ForgotPasswordRequest true "forgot"
+// @Success 200 {object} ForgotPasswordResponse
+func forgotPassword(ctx echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ForgotPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+
+	var u *models.User
+	if email, err := normalizeEmail(req.Login); err == nil {
+		u, err = userService.FindByEmail(email)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ForgotPasswordResponse{
+				Error: err.Error(),
+			})
+		}
+	} else if found, _, err := findUserByUsername(req.Login); err == nil {
+		u = found
+	}
+
+	// Only verified addresses get a link. Anything else would let whoever owns a mistyped
+	// address take over the account.
+	if u != nil && u.EmailVerified {
+		go func(u *models.User) {
+			if err := sendResetEmail(u); err != nil {
+				log.Errorf("sending password reset email: %v", err)
+			}
+		}(u)
+	}
+
+	return ctx.JSON(http.StatusOK, ForgotPasswordResponse{
+		Message: "If the account exists and has a verified email, a reset link has been sent to it.",
+	})
+}
+
+// @Summary set a new password with the token from a password reset email
+// @Description Every session of the user is logged out.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/reset [post]
+// @Tags Auth
+// @Param reset body ResetPasswordRequest true "reset"
+// @Success 200 {object} ResetPasswordResponse
+func resetPassword(ctx echo.Context) error {
+	var req ResetPasswordRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	if len(req.Password) < minPasswordLength {
+		return ctx.JSON(http.StatusBadRequest, ResetPasswordResponse{
+			Error: ErrPasswordTooShort.Error(),
+		})
+	}
+
+	claims, err := token.RedeemAction(req.Token, token.PurposeResetPassword)
+	if err == token.ErrInvalidToken || err == token.ErrTokenUsed {
+		return ctx.JSON(http.StatusBadRequest, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ResetPasswordResponse{
+			Error: token.ErrInvalidToken.Error(),
+		})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.SetPassword(userID, string(hashedPassword)); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := token.RevokeUser(userID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ResetPasswordResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, ResetPasswordResponse{})
+}
+
+// normalizeEmail validates the address and returns it in lower case
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func sendVerificationEmail(u *models.User) error {
+	t, err := token.IssueAction(u.ID, token.PurposeVerifyEmail, u.Email, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return mailer.Instance().Send(mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your Quizzer email",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm that this is your email address by opening the link below:\n%s\n\nThe link expires in %s.\n",
+			u.Username, appLink("/verify-email", t), verifyEmailTTL),
+	})
+}
+
+func sendResetEmail(u *models.User) error {
+	t, err := token.IssueAction(u.ID, token.PurposeResetPassword, u.Email, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+	return mailer.Instance().Send(mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your Quizzer password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n%s\n\nThe link expires in %s and can only be used once. If it wasn't you, ignore this email.\n",
+			u.Username, appLink("/reset-password", t), resetPasswordTTL),
+	})
+}
+
+// appLink returns a link to the page of the frontend that consumes the token
+func appLink(path string, t string) string {
+	base := os.Getenv("APP_URL")
+	if base == "" {
+		base = "http://localhost:8081"
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(t)
+}
+
+// SetEmailRequest represents the Request object for SetEmail
+type SetEmailRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailResponse represents the email of a user and whether it is verified
+type EmailResponse struct {
+	Error         string `json:"error,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"emailVerified"`
+}
+
+func newEmailResponse(u *models.User) EmailResponse {
+	return EmailResponse{
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+	}
+}
+
+// TokenRequest represents a request carrying a one-time token
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPasswordRequest represents the Request object for ForgotPassword.
+// Login is either the username or the email of the user.
+type ForgotPasswordRequest struct {
+	Login string `json:"login"`
+}
+
+// ForgotPasswordResponse represents the Response object for ForgotPassword
+type ForgotPasswordResponse struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ResetPasswordRequest represents the Request object for ResetPassword
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ResetPasswordResponse represents the Response object for ResetPassword
+type ResetPasswordResponse struct {
+	Error string `json:"error,omitempty"`
+}
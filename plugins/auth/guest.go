@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// guestPrefix starts the username of every guest. Regular users cannot pick it.
+	guestPrefix = "guest-"
+	// guestTTL is how long a guest that is not active is kept for before it gets deleted
+	guestTTL = 7 * 24 * time.Hour
+	// guestCollectionInterval is how often inactive guests are deleted
+	guestCollectionInterval = time.Hour
+
+	guestUsernameChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	guestUsernameLength = 8
+)
+
+var (
+	// ErrNotGuest is returned when claiming an account that is not a guest
+	ErrNotGuest = errors.New("account is not a guest")
+	// ErrUsernameTaken is returned when another user has the username
+	ErrUsernameTaken = userService.ErrUsernameTaken
+	// ErrReservedUsername is returned when a username starts with the guest prefix or the prefix of deleted users
+	ErrReservedUsername = errors.New("usernames starting with " + guestPrefix + " or " + models.DeletedUsernamePrefix + " are reserved")
+)
+
+// @Summary play as a guest
+// @Description Creates a temporary account. Claim it with /auth/guest/claim to keep it.
+// @Produce  application/json
+// @Router /auth/guest [post]
+// @Tags Auth
+// @Success 201 {object} GuestResponse
+func guest(ctx echo.Context) error {
+	username, err := guestUsername()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+
+	now := time.Now()
+	u, err := userService.Create(models.User{
+		ID:         primitive.NewObjectID(),
+		Username:   username,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		LastSeenAt: now,
+		IsGuest:    true,
+		Role:       rbac.RoleGuest,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+
+	pair, err := token.Issue(u, clientOf(ctx))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusCreated, newGuestResponse(pair, u.Username))
+}
+
+// @Summary turn the current guest account into a regular account
+// @Description The guest keeps its history. The tokens of the guest are revoked and new ones are returned.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/guest/claim [post]
+// @Tags Auth
+// @Param claim body ClaimGuestRequest true "claim"
+// @Success 200 {object} GuestResponse
+// @Failure 409 {object} GuestResponse
+func claimGuest(ctx echo.Context) error {
+	var req ClaimGuestRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	if req.Username == "" {
+		return ctx.JSON(http.StatusBadRequest, GuestResponse{
+			Error: "empty username",
+		})
+	}
+	if isReservedUsername(req.Username) {
+		return ctx.JSON(http.StatusBadRequest, GuestResponse{
+			Error: ErrReservedUsername.Error(),
+		})
+	}
+	if len(req.Password) < minPasswordLength {
+		return ctx.JSON(http.StatusBadRequest, GuestResponse{
+			Error: ErrPasswordTooShort.Error(),
+		})
+	}
+
+	claims := common.GetClaims(ctx)
+	u := userService.FindById(claims.Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, GuestResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if !u.IsGuest {
+		return ctx.JSON(http.StatusBadRequest, GuestResponse{
+			Error: ErrNotGuest.Error(),
+		})
+	}
+
+	taken, err := userService.UsernameExists(req.Username)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	if taken {
+		return ctx.JSON(http.StatusConflict, GuestResponse{
+			Error: ErrUsernameTaken.Error(),
+		})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	ok, err := userService.Claim(u.ID, req.Username, string(hashedPassword))
+	// another user may have taken the username since it was checked
+	if err == userService.ErrUsernameTaken {
+		return ctx.JSON(http.StatusConflict, GuestResponse{
+			Error: ErrUsernameTaken.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, GuestResponse{
+			Error: ErrNotGuest.Error(),
+		})
+	}
+
+	// the old tokens carry the guest username and role
+	if err := token.RevokeUser(u.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	u.Username = req.Username
+	u.IsGuest = false
+	u.Role = rbac.DefaultRole
+	pair, err := token.Issue(u, clientOf(ctx))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, GuestResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newGuestResponse(pair, u.Username))
+}
+
+// CollectGuests deletes the guests that have not been active for a while. It never returns.
+func CollectGuests() {
+	for {
+		n, err := userService.DeleteGuestsInactiveSince(time.Now().Add(-guestTTL))
+		if err != nil {
+			log.Errorf("deleting inactive guests: %v", err)
+		} else if n > 0 {
+			log.Infof("deleted %d inactive guests", n)
+		}
+		time.Sleep(guestCollectionInterval)
+	}
+}
+
+// guestUsername returns a random guest username that is not taken yet
+func guestUsername() (string, error) {
+	for {
+		b := make([]byte, guestUsernameLength)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(guestUsernameChars))))
+			if err != nil {
+				return "", err
+			}
+			b[i] = guestUsernameChars[n.Int64()]
+		}
+		username := guestPrefix + string(b)
+		taken, err := userService.UsernameExists(username)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return username, nil
+		}
+	}
+}
+
+// ClaimGuestRequest represents the Request object for ClaimGuest
+type ClaimGuestRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// GuestResponse represents the Response object for Guest and ClaimGuest
+type GuestResponse struct {
+	Error        string `json:"error,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	ExpiresAt    int64  `json:"expiresAt,omitempty"`
+}
+
+// isReservedUsername returns true if regular users cannot pick the username
+func isReservedUsername(username string) bool {
+	return strings.HasPrefix(username, guestPrefix) || strings.HasPrefix(username, models.DeletedUsernamePrefix)
+}
+
+func newGuestResponse(pair *token.Pair, username string) GuestResponse {
+	return GuestResponse{
+		Username:     username,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt.Unix(),
+	}
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	oidcStateService "github.com/acha-bill/quizzer_backend/packages/dblayer/oidcstate"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/oidc"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// oidcLoginTTL is how long the user has to finish logging in at the provider
+	oidcLoginTTL = 10 * time.Minute
+	// oidcCodeTTL is how long the app has to exchange the code of a finished login for tokens
+	oidcCodeTTL = time.Minute
+	// oidcStateCookie holds the state of the login started by the browser
+	oidcStateCookie = "oidc_state"
+)
+
+var (
+	usernameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+	// ErrOIDCStateMismatch is returned when the callback does not come from the browser that started the login
+	ErrOIDCStateMismatch = errors.New("login was not started from this browser")
+)
+
+// @Summary start logging in with the external identity provider
+// @Description Redirects to the provider. The provider redirects back to /auth/oidc/callback.
+// @Description The state of the login is also kept in a cookie, so that only this browser can finish it.
+// @Router /auth/oidc/login [get]
+// @Tags Auth
+// @Success 302
+func oidcLogin(ctx echo.Context) error {
+	provider := oidc.Instance()
+	if provider == nil {
+		return ctx.JSON(http.StatusNotFound, LoginResponse{
+			Error: oidc.ErrNotConfigured.Error(),
+		})
+	}
+
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	for _, err := range []error{err1, err2, err3} {
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+	}
+
+	now := time.Now()
+	err := oidcStateService.Create(models.OIDCState{
+		ID:           primitive.NewObjectID(),
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return ctx.JSON(http.StatusBadGateway, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	ctx.SetCookie(oidcCookie(ctx, state, int(oidcLoginTTL.Seconds())))
+	return ctx.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCookie returns the cookie that ties the login state to the browser. It is only sent to the callback.
+// A negative maxAge deletes it.
+func oidcCookie(ctx echo.Context, state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     strings.TrimSuffix(ctx.Request().URL.Path, "/login") + "/callback",
+		MaxAge:   maxAge,
+		Secure:   ctx.Scheme() == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// @Summary finish logging in with the external identity provider
+// @Description The external identity is linked to the user with the same verified email, or to a new user.
+// @Description Redirects to the /oidc-login page of the app with a one-time code in the token parameter,
+// @Description which the app exchanges for tokens with /auth/oidc/token.
+// @Produce  application/json
+// @Router /auth/oidc/callback [get]
+// @Tags Auth
+// @Param code query string true "authorization code"
+// @Param state query string true "state"
+// @Success 302
+// @Failure 400 {object} LoginResponse
+func oidcCallback(ctx echo.Context) error {
+	provider := oidc.Instance()
+	if provider == nil {
+		return ctx.JSON(http.StatusNotFound, LoginResponse{
+			Error: oidc.ErrNotConfigured.Error(),
+		})
+	}
+	if e := ctx.QueryParam("error"); e != "" {
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: strings.TrimSpace(e + " " + ctx.QueryParam("error_description")),
+		})
+	}
+
+	cookie, err := ctx.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(ctx.QueryParam("state"))) != 1 {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: ErrOIDCStateMismatch.Error(),
+		})
+	}
+	ctx.SetCookie(oidcCookie(ctx, "", -1))
+
+	state, err := oidcStateService.Consume(ctx.QueryParam("state"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if state == nil {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: "unknown or expired login state",
+		})
+	}
+
+	claims, err := provider.Exchange(ctx.QueryParam("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u, err := findOrCreateOIDCUser(claims)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if u.IsLocked(time.Now()) {
+		return tooManyAttempts(ctx, u.LockedUntil, time.Now())
+	}
+
+	code, err := token.IssueAction(u.ID, token.PurposeOIDCLogin, "", oidcCodeTTL)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.Redirect(http.StatusFound, appLink("/oidc-login", code))
+}
+
+// @Summary exchange the code of a finished external login for tokens
+// @Description The code can only be used once and expires after a minute.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/oidc/token [post]
+// @Tags Auth
+// @Param code body TokenRequest true "code"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} LoginResponse
+// @Failure 403 {object} LoginResponse
+// @Failure 429 {object} LoginResponse
+func oidcToken(ctx echo.Context) error {
+	var req TokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+
+	claims, err := token.RedeemAction(req.Token, token.PurposeOIDCLogin)
+	if err == token.ErrInvalidToken || err == token.ErrTokenUsed {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	u := userService.FindById(claims.Subject)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: token.ErrInvalidToken.Error(),
+		})
+	}
+	if u.IsLocked(time.Now()) {
+		return tooManyAttempts(ctx, u.LockedUntil, time.Now())
+	}
+
+	return completeLogin(ctx, u)
+}
+
+// findOrCreateOIDCUser returns the user linked to the external identity.
+// An unlinked identity is linked to the user with the same verified email or to a new user.
+func findOrCreateOIDCUser(claims *oidc.Claims) (*models.User, error) {
+	u, err := userService.FindByIdentity(claims.Issuer, claims.Subject)
+	if err != nil || u != nil {
+		return u, err
+	}
+
+	identity := models.ExternalIdentity{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+
+	email, emailErr := normalizeEmail(claims.Email)
+	if emailErr == nil && claims.EmailVerified {
+		u, err = userService.FindByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil && u.EmailVerified {
+			if err := userService.AddIdentity(u.ID, identity); err != nil {
+				return nil, err
+			}
+			return u, nil
+		}
+	}
+
+	username, err := availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	newUser := models.User{
+		ID:         primitive.NewObjectID(),
+		Username:   username,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Role:       rbac.DefaultRole,
+		Identities: []models.ExternalIdentity{identity},
+	}
+	if emailErr == nil {
+		if owner, err := userService.FindByEmail(email); err == nil && owner == nil {
+			newUser.Email = email
+			newUser.EmailVerified = claims.EmailVerified
+			if claims.EmailVerified {
+				newUser.EmailVerifiedAt = now
+			}
+		}
+	}
+	created, err := userService.Create(newUser)
+	if err == userService.ErrEmailTaken {
+		// another account took the email in the meantime
+		newUser.Email = ""
+		newUser.EmailVerified = false
+		newUser.EmailVerifiedAt = time.Time{}
+		return userService.Create(newUser)
+	}
+	return created, err
+}
+
+// availableUsername derives a username that is not taken yet from the claims of the identity
+func availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameUnsafeChars.ReplaceAllString(base, "")
+	if base == "" {
+		base = "player"
+	}
+
+	username := base
+	for i := 1; ; i++ {
+		taken, err := userService.UsernameExists(username)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	sessionService "github.com/acha-bill/quizzer_backend/packages/dblayer/session"
+	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrSessionNotFound is returned when the session does not exist or belongs to another user
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// @Summary list the active sessions of the user
+// @Description Each login starts a session that lasts as long as its refresh token.
+// @Description connected is true if the session holds a live socket.
+// @Produce  application/json
+// @Router /auth/sessions [get]
+// @Tags Auth
+// @Success 200 {object} ListSessionsResponse
+func listSessions(ctx echo.Context) error {
+	claims := common.GetClaims(ctx)
+	userID, err := primitive.ObjectIDFromHex(claims.Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ListSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	sessions, err := sessionService.FindActiveByUser(userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	res := ListSessionsResponse{
+		Sessions: []SessionResponse{},
+	}
+	for _, s := range sessions {
+		res.Sessions = append(res.Sessions, newSessionResponse(s, claims.Family))
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary revoke a session
+// @Description The tokens of the session stop working and its socket is closed.
+// @Produce  application/json
+// @Router /auth/sessions/{id} [delete]
+// @Tags Auth
+// @Param id path string true "session id"
+// @Success 200 {object} RevokeSessionsResponse
+// @Failure 404 {object} RevokeSessionsResponse
+func revokeSession(ctx echo.Context) error {
+	claims := common.GetClaims(ctx)
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, RevokeSessionsResponse{
+			Error: ErrSessionNotFound.Error(),
+		})
+	}
+
+	s, err := sessionService.FindById(id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, RevokeSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+	if s == nil || s.UserID.Hex() != claims.Id || s.RevokedAt != nil {
+		return ctx.JSON(http.StatusNotFound, RevokeSessionsResponse{
+			Error: ErrSessionNotFound.Error(),
+		})
+	}
+
+	if err := token.RevokeFamily(s.Family); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, RevokeSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+	socketserver.ServerManager().CloseSession(s.Family)
+
+	return ctx.JSON(http.StatusOK, RevokeSessionsResponse{
+		Revoked: 1,
+	})
+}
+
+// @Summary revoke every session of the user, including the current one
+// @Produce  application/json
+// @Router /auth/sessions [delete]
+// @Tags Auth
+// @Success 200 {object} RevokeSessionsResponse
+func revokeSessions(ctx echo.Context) error {
+	claims := common.GetClaims(ctx)
+	userID, err := primitive.ObjectIDFromHex(claims.Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, RevokeSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	sessions, err := sessionService.FindActiveByUser(userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, RevokeSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+	// also revokes token families that were issued before sessions were recorded
+	if err := token.RevokeUser(userID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, RevokeSessionsResponse{
+			Error: err.Error(),
+		})
+	}
+	socketserver.ServerManager().CloseSession(claims.Family)
+	for _, s := range sessions {
+		socketserver.ServerManager().CloseSession(s.Family)
+	}
+
+	return ctx.JSON(http.StatusOK, RevokeSessionsResponse{
+		Revoked: len(sessions),
+	})
+}
+
+// clientOf returns the device the request comes from
+func clientOf(ctx echo.Context) token.Client {
+	return token.Client{
+		UserAgent: ctx.Request().UserAgent(),
+		IP:        ctx.RealIP(),
+	}
+}
+
+func newSessionResponse(s *models.Session, currentFamily string) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID.Hex(),
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		Current:    s.Family == currentFamily,
+		Connected:  socketserver.ServerManager().IsSessionConnected(s.Family),
+	}
+}
+
+// SessionResponse represents a session of the user
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+	Connected  bool      `json:"connected"`
+}
+
+// ListSessionsResponse represents the Response object for ListSessions
+type ListSessionsResponse struct {
+	Error    string            `json:"error,omitempty"`
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// RevokeSessionsResponse represents the Response object for RevokeSession and RevokeSessions
+type RevokeSessionsResponse struct {
+	Error   string `json:"error,omitempty"`
+	Revoked int    `json:"revoked"`
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	loginAttemptService "github.com/acha-bill/quizzer_backend/packages/dblayer/loginattempt"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+)
+
+const (
+	// maxUserFailures is the number of failed logins a username gets before it is locked
+	maxUserFailures = 5
+	// maxIPFailures is the number of failed logins an IP address gets before it is locked
+	maxIPFailures = 20
+	// baseLockout is the first lockout. Every further failure doubles it.
+	baseLockout = time.Minute
+	// maxLockout caps the lockout duration
+	maxLockout = 24 * time.Hour
+	// failureWindow is how long a failure is remembered for
+	failureWindow = 24 * time.Hour
+)
+
+// lockoutDuration returns how long to lock out after the given number of failures.
+// It is 0 until the threshold is reached and doubles with every failure after that.
+func lockoutDuration(failures int, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	exp := failures - threshold
+	if exp > 20 {
+		return maxLockout
+	}
+	d := baseLockout << uint(exp)
+	if d > maxLockout {
+		return maxLockout
+	}
+	return d
+}
+
+// ipLockedUntil returns the time until which logins from the ip are blocked.
+// The zero time is returned if the ip is not blocked.
+func ipLockedUntil(ip string, now time.Time) (time.Time, error) {
+	attempt, err := loginAttemptService.FindByIP(ip)
+	if err != nil || attempt == nil || !attempt.IsLocked(now) {
+		return time.Time{}, err
+	}
+	return attempt.LockedUntil, nil
+}
+
+// recordIPFailure records a failed login from the ip and locks it if needed.
+func recordIPFailure(ip string, now time.Time) (lockedUntil time.Time, err error) {
+	attempt, err := loginAttemptService.RecordFailure(ip, now, now.Add(-failureWindow))
+	if err != nil {
+		return
+	}
+	if d := lockoutDuration(attempt.Failures, maxIPFailures); d > 0 {
+		lockedUntil = now.Add(d)
+		err = loginAttemptService.SetLockedUntil(ip, lockedUntil)
+	}
+	return
+}
+
+// recordUserFailure records a failed login for the user and locks the account if needed.
+func recordUserFailure(u *models.User, now time.Time) (lockedUntil time.Time, err error) {
+	updated, err := userService.RecordFailedLogin(u.ID, now, now.Add(-failureWindow))
+	if err != nil {
+		return
+	}
+	if d := lockoutDuration(updated.FailedLogins, maxUserFailures); d > 0 {
+		lockedUntil = now.Add(d)
+		err = userService.SetLockedUntil(u.ID, lockedUntil)
+	}
+	return
+}
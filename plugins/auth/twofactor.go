@@ -0,0 +1,448 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/acha-bill/quizzer_backend/packages/totp"
+	"github.com/labstack/echo/v4"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// totpIssuer is the name authenticator apps show next to the code
+	totpIssuer = "Quizzer"
+	// mfaLoginTTL is how long the user has to enter the second factor after the password
+	mfaLoginTTL = 5 * time.Minute
+	// recoveryCodeCount is the number of recovery codes generated at once
+	recoveryCodeCount = 10
+
+	recoveryCodeChars  = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeLength = 10
+)
+
+var (
+	// ErrInvalidCode is returned when a two-factor or recovery code is wrong
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrTOTPAlreadyEnabled is returned when enrolling while two-factor authentication is on
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTOTPNotEnabled is returned when two-factor authentication is needed but is off
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrTOTPRequired is returned when disabling two-factor authentication the role requires
+	ErrTOTPRequired = errors.New("two-factor authentication is required for your role")
+)
+
+// completeLogin responds to a user that has proven who they are with their first factor.
+// Users with two-factor authentication get a token to continue with at /auth/2fa/verify.
+func completeLogin(ctx echo.Context, u *models.User) error {
+	if u.IsSuspended(time.Now()) {
+		return ctx.JSON(http.StatusForbidden, LoginResponse{
+			Error: token.ErrAccountSuspended.Error(),
+		})
+	}
+	if u.TOTPEnabled {
+		mfaToken, err := token.IssueAction(u.ID, token.PurposeMFA, "", mfaLoginTTL)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusOK, LoginResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+	}
+
+	pair, err := token.Issue(u, clientOf(ctx))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newLoginResponse(pair))
+}
+
+// @Summary finish logging in with a two-factor or recovery code
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/2fa/verify [post]
+// @Tags Auth
+// @Param verify body VerifyTwoFactorRequest true "verify"
+// @Success 200 {object} LoginResponse
+// @Failure 401 {object} LoginResponse
+// @Failure 403 {object} LoginResponse
+// @Failure 429 {object} LoginResponse
+func verifyTwoFactor(ctx echo.Context) error {
+	var req VerifyTwoFactorRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+
+	claims, err := token.VerifyAction(req.MFAToken, token.PurposeMFA)
+	if err != nil {
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	u := userService.FindById(claims.Subject)
+	if u.ID.IsZero() || !u.TOTPEnabled {
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: token.ErrInvalidToken.Error(),
+		})
+	}
+	now := time.Now()
+	if u.IsLocked(now) {
+		return tooManyAttempts(ctx, u.LockedUntil, now)
+	}
+	if u.IsSuspended(now) {
+		return ctx.JSON(http.StatusForbidden, LoginResponse{
+			Error: token.ErrAccountSuspended.Error(),
+		})
+	}
+
+	ok, err := checkSecondFactor(u, req.Code, req.RecoveryCode)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		lockedUntil, err := recordUserFailure(u, now)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+				Error: err.Error(),
+			})
+		}
+		if !lockedUntil.IsZero() {
+			return tooManyAttempts(ctx, lockedUntil, now)
+		}
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: ErrInvalidCode.Error(),
+		})
+	}
+
+	if _, err := token.RedeemAction(req.MFAToken, token.PurposeMFA); err != nil {
+		return ctx.JSON(http.StatusUnauthorized, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.ClearFailedLogins(u.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	pair, err := token.Issue(u, clientOf(ctx), true)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, LoginResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newLoginResponse(pair))
+}
+
+// @Summary start enrolling in two-factor authentication
+// @Description Returns the secret as an otpauth URI and as a QR code. Confirm with /auth/2fa/confirm.
+// @Produce  application/json
+// @Router /auth/2fa/enroll [post]
+// @Tags Auth
+// @Success 200 {object} EnrollTwoFactorResponse
+func enrollTwoFactor(ctx echo.Context) error {
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, EnrollTwoFactorResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if u.TOTPEnabled {
+		return ctx.JSON(http.StatusConflict, EnrollTwoFactorResponse{
+			Error: ErrTOTPAlreadyEnabled.Error(),
+		})
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EnrollTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.SetTOTPSecret(u.ID, secret); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EnrollTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	uri := totp.URI(totpIssuer, u.Username, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, EnrollTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, EnrollTwoFactorResponse{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// @Summary confirm the two-factor enrolment with a first code
+// @Description Returns the recovery codes, which are only shown once, and new tokens that carry the second factor.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/2fa/confirm [post]
+// @Tags Auth
+// @Param confirm body TwoFactorCodeRequest true "confirm"
+// @Success 200 {object} ConfirmTwoFactorResponse
+func confirmTwoFactor(ctx echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	claims := common.GetClaims(ctx)
+	u := userService.FindById(claims.Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, ConfirmTwoFactorResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if u.TOTPEnabled {
+		return ctx.JSON(http.StatusConflict, ConfirmTwoFactorResponse{
+			Error: ErrTOTPAlreadyEnabled.Error(),
+		})
+	}
+	if u.TOTPSecret == "" {
+		return ctx.JSON(http.StatusBadRequest, ConfirmTwoFactorResponse{
+			Error: "start the enrolment first",
+		})
+	}
+	step, ok := totp.Validate(u.TOTPSecret, req.Code, time.Now(), u.TOTPLastStep)
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, ConfirmTwoFactorResponse{
+			Error: ErrInvalidCode.Error(),
+		})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.EnableTOTP(u.ID, step, hashes); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// swap the tokens for ones that carry the second factor
+	if err := token.RevokeFamily(claims.Family); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	pair, err := token.Issue(u, clientOf(ctx), true)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, ConfirmTwoFactorResponse{
+		RecoveryCodes: codes,
+		Login:         newLoginResponse(pair),
+	})
+}
+
+// @Summary turn off two-factor authentication
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/2fa/disable [post]
+// @Tags Auth
+// @Param disable body TwoFactorCodeRequest true "disable"
+// @Success 200 {object} TwoFactorResponse
+func disableTwoFactor(ctx echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, TwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u, status, err := twoFactorUser(ctx)
+	if err != nil {
+		return ctx.JSON(status, TwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	if common.RequireAdmin2FA() && rbac.IsPrivileged(u.Role) {
+		return ctx.JSON(http.StatusForbidden, TwoFactorResponse{
+			Error: ErrTOTPRequired.Error(),
+		})
+	}
+	ok, err := checkSecondFactor(u, req.Code, req.RecoveryCode)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, TwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, TwoFactorResponse{
+			Error: ErrInvalidCode.Error(),
+		})
+	}
+
+	if err := userService.DisableTOTP(u.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, TwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, TwoFactorResponse{})
+}
+
+// @Summary replace the recovery codes
+// @Accept  application/json
+// @Produce  application/json
+// @Router /auth/2fa/recovery-codes [post]
+// @Tags Auth
+// @Param codes body TwoFactorCodeRequest true "codes"
+// @Success 200 {object} ConfirmTwoFactorResponse
+func regenerateRecoveryCodes(ctx echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u, status, err := twoFactorUser(ctx)
+	if err != nil {
+		return ctx.JSON(status, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	ok, err := checkSecondFactor(u, req.Code, "")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, ConfirmTwoFactorResponse{
+			Error: ErrInvalidCode.Error(),
+		})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.SetRecoveryCodes(u.ID, hashes); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ConfirmTwoFactorResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, ConfirmTwoFactorResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// twoFactorUser returns the current user if they have two-factor authentication enabled
+func twoFactorUser(ctx echo.Context) (*models.User, int, error) {
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return nil, http.StatusNotFound, ErrUserNotFound
+	}
+	if !u.TOTPEnabled {
+		return nil, http.StatusBadRequest, ErrTOTPNotEnabled
+	}
+	return u, http.StatusOK, nil
+}
+
+// checkSecondFactor checks the code, or the recovery code if there is one, and uses it up
+func checkSecondFactor(u *models.User, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return userService.UseRecoveryCode(u.ID, hashRecoveryCode(recoveryCode))
+	}
+	step, ok := totp.Validate(u.TOTPSecret, code, time.Now(), u.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	return userService.UseTOTPStep(u.ID, step)
+}
+
+// generateRecoveryCodes returns new recovery codes and the hashes to store
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength)
+		for j := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeChars))))
+			if err != nil {
+				return nil, nil, err
+			}
+			b[j] = recoveryCodeChars[n.Int64()]
+		}
+		code := string(b[:recoveryCodeLength/2]) + "-" + string(b[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyTwoFactorRequest represents the Request object for VerifyTwoFactor.
+// Either the code or a recovery code must be set.
+type VerifyTwoFactorRequest struct {
+	MFAToken     string `json:"mfaToken"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// TwoFactorCodeRequest represents a request proven with a two-factor code or a recovery code
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// TwoFactorResponse represents the Response object of the two-factor endpoints
+type TwoFactorResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// EnrollTwoFactorResponse represents the Response object for EnrollTwoFactor.
+// QRCode is a PNG data URI of the URI.
+type EnrollTwoFactorResponse struct {
+	Error  string `json:"error,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	URI    string `json:"uri,omitempty"`
+	QRCode string `json:"qrCode,omitempty"`
+}
+
+// ConfirmTwoFactorResponse represents the Response object for ConfirmTwoFactor
+type ConfirmTwoFactorResponse struct {
+	Error         string        `json:"error,omitempty"`
+	RecoveryCodes []string      `json:"recoveryCodes,omitempty"`
+	Login         LoginResponse `json:"login,omitempty"`
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary get a ticket to open a socket
+// @Description The ticket is valid for 30 seconds and can be used once, from the origin of this request.
+// @Description Open the socket with /ws?ticket=<ticket>.
+// @Produce  application/json
+// @Router /auth/ws-ticket [post]
+// @Tags Auth
+// @Success 201 {object} WsTicketResponse
+func wsTicket(ctx echo.Context) error {
+	ticket, expiresAt, err := token.IssueTicket(common.GetClaims(ctx), ctx.Request().Header.Get(echo.HeaderOrigin))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, WsTicketResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusCreated, WsTicketResponse{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
+
+// WsTicketResponse represents the Response object for WsTicket
+type WsTicketResponse struct {
+	Error     string `json:"error,omitempty"`
+	Ticket    string `json:"ticket,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+}
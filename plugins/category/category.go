@@ -1,25 +1,37 @@
 package category
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/acha-bill/quizzer_backend/common"
 	"github.com/acha-bill/quizzer_backend/models"
 	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"github.com/acha-bill/quizzer_backend/plugins"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
-	PluginName = "question"
+	PluginName = "category"
 )
 
 var (
 	plugin *Category
 	once   sync.Once
+	// ErrCategoryNotFound is returned when the category does not exist
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrEmptyName is returned when the name of the category is empty
+	ErrEmptyName = errors.New("name must not be empty")
+	// ErrDuplicateName is returned when another category has the same name
+	ErrDuplicateName = errors.New("a category with this name already exists")
+	// ErrCategoryInUse is returned when deleting a category that still has questions
+	ErrCategoryInUse = errors.New("category still has questions")
 )
 
 type Category struct {
@@ -27,15 +39,15 @@ type Category struct {
 	handlers []*plugins.PluginHandler
 }
 
-func (plugin *Category) AddHandler(method string, path string, handler func(echo.Context) error, authLevel ...plugins.AuthLevel) {
+func (plugin *Category) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
 	pluginHandler := &plugins.PluginHandler{
-		Path:      path,
-		Handler:   handler,
-		Method:    method,
-		AuthLevel: plugins.AuthLevelAdmin,
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionCategoryWrite,
 	}
-	if len(authLevel) > 0 {
-		pluginHandler.AuthLevel = authLevel[0]
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
 	}
 	plugin.handlers = append(plugin.handlers, pluginHandler)
 }
@@ -65,10 +77,10 @@ func Plugin() *Category {
 func init() {
 	category := Plugin()
 	category.AddHandler(http.MethodPost, "/", create)
-	category.AddHandler(http.MethodGet, "/", find)
+	category.AddHandler(http.MethodGet, "/", find, rbac.PermissionCategoryRead)
+	category.AddHandler(http.MethodGet, "/:id", findOne, rbac.PermissionCategoryRead)
 	category.AddHandler(http.MethodPut, "/:id", edit)
-	// TODO: implement
-	//category.AddHandler(http.MethodGet, "/:id", remove)
+	category.AddHandler(http.MethodDelete, "/:id", remove)
 }
 
 // @Summary list all categories
@@ -76,128 +88,231 @@ func init() {
 // @Produce  json
 // @Router /category/ [get]
 // @Tags Category
-// @Success 201 {object} FindCategoryResponse
+// @Success 200 {object} FindCategoryResponse
 func find(ctx echo.Context) error {
-	if !common.IsAdmin(ctx) {
-		return ctx.JSON(http.StatusUnauthorized, FindCategoryResponse{
-			Error: "Unauthorized",
+	categories, err := categoryService.FindAll()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FindCategoryResponse{
+			Error: err.Error(),
 		})
 	}
-	res, err := categoryService.FindAll()
+	res := FindCategoryResponse{
+		Categories: []CategoryResponse{},
+	}
+	for _, c := range categories {
+		res.Categories = append(res.Categories, newCategoryResponse(c))
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary get a category
+// @Produce  json
+// @Router /category/{id} [get]
+// @Tags Category
+// @Param id path string true "category id"
+// @Success 200 {object} CategoryResponse
+// @Failure 404 {object} CategoryResponse
+func findOne(ctx echo.Context) error {
+	category, err := categoryService.FindById(ctx.Param("id"))
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, FindCategoryResponse{
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
 			Error: err.Error(),
 		})
 	}
-	return ctx.JSON(http.StatusBadRequest, FindCategoryResponse{
-		Categories: res,
-	})
+	if category == nil {
+		return ctx.JSON(http.StatusNotFound, CategoryResponse{
+			Error: ErrCategoryNotFound.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newCategoryResponse(category))
 }
 
 // @Summary create category
+// @Description The name must not be empty or already used by another category, ignoring case.
 // @Accept  json
 // @Produce  json
 // @Router /category/ [post]
 // @Tags Category
-// @Param question body CreateCategoryRequest true "create"
-// @Success 201 {object} CreateCategoryResponse
+// @Param category body CreateCategoryRequest true "create"
+// @Success 201 {object} CategoryResponse
+// @Failure 409 {object} CategoryResponse
+// @Failure 422 {object} CategoryResponse
 func create(ctx echo.Context) error {
-	if !common.IsAdmin(ctx) {
-		return ctx.JSON(http.StatusUnauthorized, CreateCategoryResponse{
-			Error: "Unauthorized",
-		})
-	}
 	var req CreateCategoryRequest
 	if err := ctx.Bind(&req); err != nil {
-		return ctx.JSON(http.StatusBadRequest, CreateCategoryResponse{
+		return ctx.JSON(http.StatusBadRequest, CategoryResponse{
 			Error: err.Error(),
 		})
 	}
-
-	q := models.Category{
-		ID:        primitive.NewObjectID(),
-		Name:      req.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	name := strings.TrimSpace(req.Name)
+	if status, err := checkName(name, primitive.NilObjectID); err != nil {
+		return ctx.JSON(status, CategoryResponse{
+			Error: err.Error(),
+		})
 	}
 
-	created, err := categoryService.Create(q)
+	now := time.Now()
+	created, err := categoryService.Create(models.Category{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, CreateCategoryResponse{
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
 			Error: err.Error(),
 		})
 	}
-
-	return ctx.JSON(http.StatusBadRequest, created)
+	return ctx.JSON(http.StatusCreated, newCategoryResponse(created))
 }
 
-// @Summary edit category
+// @Summary rename a category
 // @Accept  json
 // @Produce  json
-// @Router /category/:id [put]
+// @Router /category/{id} [put]
 // @Tags Category
-// @Param question body EditCategoryRequest true "create"
-// @Success 201 {object} EditCategoryResponse
+// @Param id path string true "category id"
+// @Param category body EditCategoryRequest true "edit"
+// @Success 200 {object} CategoryResponse
+// @Failure 404 {object} CategoryResponse
+// @Failure 409 {object} CategoryResponse
+// @Failure 422 {object} CategoryResponse
 func edit(ctx echo.Context) error {
-	if !common.IsAdmin(ctx) {
-		return ctx.JSON(http.StatusUnauthorized, EditCategoryResponse{
-			Error: "Unauthorized",
-		})
-	}
 	var req EditCategoryRequest
 	if err := ctx.Bind(&req); err != nil {
-		return ctx.JSON(http.StatusBadRequest, EditCategoryResponse{
+		return ctx.JSON(http.StatusBadRequest, CategoryResponse{
 			Error: err.Error(),
 		})
 	}
 
-	categoryID := ctx.Param("id")
-	category, err := categoryService.FindById(categoryID)
+	category, err := categoryService.FindById(ctx.Param("id"))
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, EditCategoryResponse{
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
 			Error: err.Error(),
 		})
 	}
 	if category == nil {
-		return ctx.JSON(http.StatusBadRequest, EditCategoryResponse{
-			Error: "Not found",
+		return ctx.JSON(http.StatusNotFound, CategoryResponse{
+			Error: ErrCategoryNotFound.Error(),
+		})
+	}
+	name := strings.TrimSpace(req.Name)
+	if status, err := checkName(name, category.ID); err != nil {
+		return ctx.JSON(status, CategoryResponse{
+			Error: err.Error(),
 		})
 	}
 
-	category.Name = req.Name
-	err = categoryService.UpdateById(categoryID, *category)
+	category.Name = name
+	category.UpdatedAt = time.Now()
+	err = categoryService.UpdateById(category.ID.Hex(), *category)
+	if err == categoryService.ErrNoCategoryUpdated {
+		return ctx.JSON(http.StatusNotFound, CategoryResponse{
+			Error: ErrCategoryNotFound.Error(),
+		})
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, EditCategoryResponse{
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
 			Error: err.Error(),
 		})
 	}
+	return ctx.JSON(http.StatusOK, newCategoryResponse(category))
+}
 
-	return ctx.JSON(http.StatusBadRequest, category)
+// @Summary delete a category
+// @Description Only categories without questions can be deleted.
+// @Produce  json
+// @Router /category/{id} [delete]
+// @Tags Category
+// @Param id path string true "category id"
+// @Success 200 {object} CategoryResponse
+// @Failure 404 {object} CategoryResponse
+// @Failure 409 {object} CategoryResponse
+func remove(ctx echo.Context) error {
+	category, err := categoryService.FindById(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
+			Error: err.Error(),
+		})
+	}
+	if category == nil {
+		return ctx.JSON(http.StatusNotFound, CategoryResponse{
+			Error: ErrCategoryNotFound.Error(),
+		})
+	}
+	questions, err := questionService.Count(bson.D{{Key: "categoryId", Value: category.ID}})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
+			Error: err.Error(),
+		})
+	}
+	if questions > 0 {
+		return ctx.JSON(http.StatusConflict, CategoryResponse{
+			Error: ErrCategoryInUse.Error(),
+		})
+	}
+
+	err = categoryService.DeleteById(category.ID.Hex())
+	if err == categoryService.ErrNoCategoriesDeleted {
+		return ctx.JSON(http.StatusNotFound, CategoryResponse{
+			Error: ErrCategoryNotFound.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, CategoryResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, CategoryResponse{
+		ID: category.ID.Hex(),
+	})
+}
+
+// checkName returns an error and its status if the name cannot be given to the category with the id
+func checkName(name string, id primitive.ObjectID) (int, error) {
+	if name == "" {
+		return http.StatusUnprocessableEntity, ErrEmptyName
+	}
+	existing, err := categoryService.FindByName(name)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if existing != nil && existing.ID != id {
+		return http.StatusConflict, ErrDuplicateName
+	}
+	return http.StatusOK, nil
+}
+
+func newCategoryResponse(c *models.Category) CategoryResponse {
+	return CategoryResponse{
+		ID:        c.ID.Hex(),
+		Name:      c.Name,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+// CategoryResponse represents a category
+type CategoryResponse struct {
+	Error     string    `json:"error,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
 }
 
 // FindCategoryResponse is the find response
 type FindCategoryResponse struct {
 	Error      string             `json:"error,omitempty"`
-	Categories []*models.Category `json:"categories"`
+	Categories []CategoryResponse `json:"categories"`
 }
 
+// EditCategoryRequest is the request for edit category
 type EditCategoryRequest struct {
-	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
-type EditCategoryResponse struct {
-	Error    string           `json:"error,omitempty"`
-	Category *models.Category `json:"category"`
-}
-
 // CreateCategoryRequest is the request for create category
 type CreateCategoryRequest struct {
 	Name string `json:"name"`
 }
-
-// CreateCategoryResponse is the response for create category
-type CreateCategoryResponse struct {
-	Error    string           `json:"error,omitempty"`
-	Category *models.Category `json:"category"`
-}
@@ -0,0 +1,186 @@
+package challenge
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/plugins"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// PluginName defines the name of the plugin
+	PluginName = "challenge"
+)
+
+var (
+	plugin *Challenge
+	once   sync.Once
+)
+
+// Challenge structure
+type Challenge struct {
+	name     string
+	handlers []*plugins.PluginHandler
+}
+
+// AddHandler Method definition from interface
+func (plugin *Challenge) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
+	pluginHandler := &plugins.PluginHandler{
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionGamePlay,
+	}
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
+	}
+	plugin.handlers = append(plugin.handlers, pluginHandler)
+}
+
+// Handlers Method definition from interface
+func (plugin *Challenge) Handlers() []*plugins.PluginHandler {
+	return plugin.handlers
+}
+
+// Name defines the name of the plugin
+func (plugin *Challenge) Name() string {
+	return plugin.name
+}
+
+// NewPlugin returns the new plugin
+func NewPlugin() *Challenge {
+	plugin := &Challenge{
+		name: PluginName,
+	}
+	return plugin
+}
+
+// Plugin returns an instance of the plugin
+func Plugin() *Challenge {
+	once.Do(func() {
+		plugin = NewPlugin()
+	})
+	return plugin
+}
+
+func init() {
+	challenge := Plugin()
+	challenge.AddHandler(http.MethodPost, "/:username", sendChallenge)
+	challenge.AddHandler(http.MethodPost, "/:id/accept", acceptChallenge)
+	challenge.AddHandler(http.MethodPost, "/:id/decline", declineChallenge)
+}
+
+// @Summary challenge a user to a game
+// @Description Both users must be connected to the socket and the challenged user must not be in a game.
+// @Description The challenged user gets a challenge message and has 30 seconds to accept it.
+// @Produce  application/json
+// @Router /challenge/{username} [post]
+// @Tags Challenge
+// @Param username path string true "username"
+// @Success 201 {object} ChallengeResponse
+// @Failure 403 {object} ChallengeResponse
+// @Failure 409 {object} ChallengeResponse
+func sendChallenge(ctx echo.Context) error {
+	wsConn := socketserver.ServerManager().GetByUsername(common.GetUsername(ctx))
+	if wsConn == nil {
+		return ctx.JSON(http.StatusConflict, ChallengeResponse{
+			Error: socketserver.ErrUserNotConnected.Error(),
+		})
+	}
+
+	c, err := socketserver.NewChallenge(wsConn, ctx.Param("username"))
+	if err == socketserver.ErrUserNotConnected {
+		return ctx.JSON(http.StatusNotFound, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	if err == socketserver.ErrUserBlocked {
+		return ctx.JSON(http.StatusForbidden, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	if err == socketserver.ErrUserInGame {
+		return ctx.JSON(http.StatusConflict, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusCreated, ChallengeResponse{
+		ID:        c.ID,
+		Username:  c.To,
+		ExpiresAt: c.ExpiresAt.Unix(),
+	})
+}
+
+// @Summary accept a challenge and start the game
+// @Produce  application/json
+// @Router /challenge/{id}/accept [post]
+// @Tags Challenge
+// @Param id path string true "challenge id"
+// @Success 200 {object} ChallengeResponse
+// @Failure 404 {object} ChallengeResponse
+func acceptChallenge(ctx echo.Context) error {
+	wsConn := socketserver.ServerManager().GetByUsername(common.GetUsername(ctx))
+	if wsConn == nil {
+		return ctx.JSON(http.StatusConflict, ChallengeResponse{
+			Error: socketserver.ErrUserNotConnected.Error(),
+		})
+	}
+
+	err := socketserver.AcceptChallenge(ctx.Param("id"), wsConn)
+	if err == socketserver.ErrChallengeNotFound {
+		return ctx.JSON(http.StatusNotFound, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, ChallengeResponse{
+		ID: ctx.Param("id"),
+	})
+}
+
+// @Summary decline a challenge, or cancel one that was sent
+// @Produce  application/json
+// @Router /challenge/{id}/decline [post]
+// @Tags Challenge
+// @Param id path string true "challenge id"
+// @Success 200 {object} ChallengeResponse
+// @Failure 404 {object} ChallengeResponse
+func declineChallenge(ctx echo.Context) error {
+	userID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := socketserver.DeclineChallenge(ctx.Param("id"), userID); err != nil {
+		return ctx.JSON(http.StatusNotFound, ChallengeResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, ChallengeResponse{
+		ID: ctx.Param("id"),
+	})
+}
+
+// ChallengeResponse represents the Response object of the challenge requests
+type ChallengeResponse struct {
+	Error     string `json:"error,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+}
@@ -0,0 +1,374 @@
+package friend
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	friendshipService "github.com/acha-bill/quizzer_backend/packages/dblayer/friendship"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/plugins"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// PluginName defines the name of the plugin
+	PluginName = "friend"
+)
+
+var (
+	plugin *Friend
+	once   sync.Once
+	// ErrUserNotFound is returned when the user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSelfFriendship is returned when a user tries to befriend themselves
+	ErrSelfFriendship = errors.New("you cannot be your own friend")
+	// ErrAlreadyFriends is returned when the users are already friends
+	ErrAlreadyFriends = errors.New("already friends")
+	// ErrRequestAlreadySent is returned when a friend request is already pending
+	ErrRequestAlreadySent = errors.New("friend request already sent")
+	// ErrNoFriendRequest is returned when there is no pending request to answer
+	ErrNoFriendRequest = errors.New("no pending friend request")
+	// ErrNotFriends is returned when removing a user that is not a friend
+	ErrNotFriends = errors.New("not friends")
+	// ErrUserBlocked is returned when one of the users has blocked the other
+	ErrUserBlocked = errors.New("you cannot befriend this user")
+)
+
+// Friend structure
+type Friend struct {
+	name     string
+	handlers []*plugins.PluginHandler
+}
+
+// AddHandler Method definition from interface
+func (plugin *Friend) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
+	pluginHandler := &plugins.PluginHandler{
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionGamePlay,
+	}
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
+	}
+	plugin.handlers = append(plugin.handlers, pluginHandler)
+}
+
+// Handlers Method definition from interface
+func (plugin *Friend) Handlers() []*plugins.PluginHandler {
+	return plugin.handlers
+}
+
+// Name defines the name of the plugin
+func (plugin *Friend) Name() string {
+	return plugin.name
+}
+
+// NewPlugin returns the new plugin
+func NewPlugin() *Friend {
+	plugin := &Friend{
+		name: PluginName,
+	}
+	return plugin
+}
+
+// Plugin returns an instance of the plugin
+func Plugin() *Friend {
+	once.Do(func() {
+		plugin = NewPlugin()
+	})
+	return plugin
+}
+
+func init() {
+	friend := Plugin()
+	friend.AddHandler(http.MethodGet, "/", listFriends)
+	friend.AddHandler(http.MethodPost, "/request/:username", sendRequest)
+	friend.AddHandler(http.MethodPost, "/accept/:username", acceptRequest)
+	friend.AddHandler(http.MethodPost, "/decline/:username", declineRequest)
+	friend.AddHandler(http.MethodDelete, "/:username", removeFriend)
+}
+
+// @Summary list friends with their presence, and pending friend requests
+// @Produce  application/json
+// @Router /friend/ [get]
+// @Tags Friend
+// @Success 200 {object} ListFriendsResponse
+func listFriends(ctx echo.Context) error {
+	userID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ListFriendsResponse{
+			Error: err.Error(),
+		})
+	}
+	friendships, err := friendshipService.FindByUser(userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListFriendsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	var ids []primitive.ObjectID
+	for _, f := range friendships {
+		ids = append(ids, f.Other(userID))
+	}
+	users := make(map[primitive.ObjectID]*models.User)
+	if len(ids) > 0 {
+		found, err := userService.Find(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ListFriendsResponse{
+				Error: err.Error(),
+			})
+		}
+		for _, u := range found {
+			users[u.ID] = u
+		}
+	}
+
+	res := ListFriendsResponse{
+		Friends:  []FriendResponse{},
+		Incoming: []FriendResponse{},
+		Outgoing: []FriendResponse{},
+	}
+	for _, f := range friendships {
+		u, ok := users[f.Other(userID)]
+		if !ok {
+			continue
+		}
+		friend := FriendResponse{
+			Username:    u.Username,
+			DisplayName: u.DisplayName,
+			ProfileURL:  u.ProfileURL,
+			Since:       f.CreatedAt,
+		}
+		switch {
+		case f.Status == models.FriendshipAccepted:
+			friend.Since = f.AcceptedAt
+			friend.Presence = socketserver.UserPresence(u.ID)
+			res.Friends = append(res.Friends, friend)
+		case f.AddresseeID == userID:
+			res.Incoming = append(res.Incoming, friend)
+		default:
+			res.Outgoing = append(res.Outgoing, friend)
+		}
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary send a friend request
+// @Description If the other user has already sent a request, it is accepted.
+// @Produce  application/json
+// @Router /friend/request/{username} [post]
+// @Tags Friend
+// @Param username path string true "username"
+// @Success 201 {object} FriendshipResponse
+// @Failure 403 {object} FriendshipResponse
+// @Failure 409 {object} FriendshipResponse
+func sendRequest(ctx echo.Context) error {
+	userID, other, existing, status, err := findFriendship(ctx)
+	if err != nil {
+		return ctx.JSON(status, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	blocked, err := blockService.IsBlocked(userID, other.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	if blocked {
+		return ctx.JSON(http.StatusForbidden, FriendshipResponse{
+			Error: ErrUserBlocked.Error(),
+		})
+	}
+
+	if existing != nil {
+		switch {
+		case existing.Status == models.FriendshipAccepted:
+			err = ErrAlreadyFriends
+		case existing.RequesterID == userID:
+			err = ErrRequestAlreadySent
+		default:
+			return accept(ctx, existing, other)
+		}
+		return ctx.JSON(http.StatusConflict, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+
+	_, err = friendshipService.Create(models.Friendship{
+		ID:          primitive.NewObjectID(),
+		Pair:        models.FriendshipPair(userID, other.ID),
+		RequesterID: userID,
+		AddresseeID: other.ID,
+		Status:      models.FriendshipPending,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	socketserver.NotifyFriendRequest(other.ID, common.GetUsername(ctx))
+	return ctx.JSON(http.StatusCreated, FriendshipResponse{
+		Username: other.Username,
+		Status:   models.FriendshipPending,
+	})
+}
+
+// @Summary accept a friend request
+// @Produce  application/json
+// @Router /friend/accept/{username} [post]
+// @Tags Friend
+// @Param username path string true "username"
+// @Success 200 {object} FriendshipResponse
+// @Failure 404 {object} FriendshipResponse
+func acceptRequest(ctx echo.Context) error {
+	userID, other, existing, status, err := findFriendship(ctx)
+	if err != nil {
+		return ctx.JSON(status, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	if existing == nil || existing.Status != models.FriendshipPending || existing.AddresseeID != userID {
+		return ctx.JSON(http.StatusNotFound, FriendshipResponse{
+			Error: ErrNoFriendRequest.Error(),
+		})
+	}
+	return accept(ctx, existing, other)
+}
+
+// @Summary decline a friend request, or cancel one that was sent
+// @Produce  application/json
+// @Router /friend/decline/{username} [post]
+// @Tags Friend
+// @Param username path string true "username"
+// @Success 200 {object} FriendshipResponse
+// @Failure 404 {object} FriendshipResponse
+func declineRequest(ctx echo.Context) error {
+	_, other, existing, status, err := findFriendship(ctx)
+	if err != nil {
+		return ctx.JSON(status, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	if existing == nil || existing.Status != models.FriendshipPending {
+		return ctx.JSON(http.StatusNotFound, FriendshipResponse{
+			Error: ErrNoFriendRequest.Error(),
+		})
+	}
+	if err := friendshipService.DeleteByID(existing.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, FriendshipResponse{
+		Username: other.Username,
+	})
+}
+
+// @Summary remove a friend
+// @Produce  application/json
+// @Router /friend/{username} [delete]
+// @Tags Friend
+// @Param username path string true "username"
+// @Success 200 {object} FriendshipResponse
+// @Failure 404 {object} FriendshipResponse
+func removeFriend(ctx echo.Context) error {
+	_, other, existing, status, err := findFriendship(ctx)
+	if err != nil {
+		return ctx.JSON(status, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	if existing == nil || existing.Status != models.FriendshipAccepted {
+		return ctx.JSON(http.StatusNotFound, FriendshipResponse{
+			Error: ErrNotFriends.Error(),
+		})
+	}
+	if err := friendshipService.DeleteByID(existing.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, FriendshipResponse{
+		Username: other.Username,
+	})
+}
+
+// accept accepts the pending friendship and tells the requester
+func accept(ctx echo.Context, friendship *models.Friendship, requester *models.User) error {
+	ok, err := friendshipService.Accept(friendship.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FriendshipResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		return ctx.JSON(http.StatusNotFound, FriendshipResponse{
+			Error: ErrNoFriendRequest.Error(),
+		})
+	}
+	socketserver.NotifyFriendAccepted(requester.ID, common.GetUsername(ctx), friendship.AddresseeID)
+	return ctx.JSON(http.StatusOK, FriendshipResponse{
+		Username: requester.Username,
+		Status:   models.FriendshipAccepted,
+		Presence: socketserver.UserPresence(requester.ID),
+	})
+}
+
+// findFriendship finds the user of the username path parameter and their friendship with the user of the request.
+// The friendship is nil if there is none.
+func findFriendship(ctx echo.Context) (primitive.ObjectID, *models.User, *models.Friendship, int, error) {
+	userID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return userID, nil, nil, http.StatusBadRequest, err
+	}
+	other := userService.FindByUsername(ctx.Param("username"))
+	if other.ID.IsZero() {
+		return userID, nil, nil, http.StatusNotFound, ErrUserNotFound
+	}
+	if other.ID == userID {
+		return userID, nil, nil, http.StatusBadRequest, ErrSelfFriendship
+	}
+	existing, err := friendshipService.FindBetween(userID, other.ID)
+	if err != nil {
+		return userID, nil, nil, http.StatusInternalServerError, err
+	}
+	return userID, other, existing, http.StatusOK, nil
+}
+
+// FriendResponse represents a friend or a user of a friend request
+type FriendResponse struct {
+	Username    string                `json:"username"`
+	DisplayName string                `json:"displayName,omitempty"`
+	ProfileURL  string                `json:"profileURL,omitempty"`
+	Presence    socketserver.Presence `json:"presence,omitempty"`
+	Since       time.Time             `json:"since"`
+}
+
+// ListFriendsResponse represents the Response object for ListFriends
+type ListFriendsResponse struct {
+	Error    string           `json:"error,omitempty"`
+	Friends  []FriendResponse `json:"friends"`
+	Incoming []FriendResponse `json:"incoming"`
+	Outgoing []FriendResponse `json:"outgoing"`
+}
+
+// FriendshipResponse represents the Response object of the friend requests
+type FriendshipResponse struct {
+	Error    string                  `json:"error,omitempty"`
+	Username string                  `json:"username,omitempty"`
+	Status   models.FriendshipStatus `json:"status,omitempty"`
+	Presence socketserver.Presence   `json:"presence,omitempty"`
+}
@@ -0,0 +1,244 @@
+package moderation
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	friendshipService "github.com/acha-bill/quizzer_backend/packages/dblayer/friendship"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/plugins"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// PluginName defines the name of the plugin
+	PluginName = "moderation"
+)
+
+var (
+	plugin *Moderation
+	once   sync.Once
+	// ErrUserNotFound is returned when the user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSelfBlock is returned when a user tries to block themselves
+	ErrSelfBlock = errors.New("you cannot block yourself")
+	// ErrNotBlocked is returned when unblocking a user that is not blocked
+	ErrNotBlocked = errors.New("user is not blocked")
+)
+
+// Moderation structure
+type Moderation struct {
+	name     string
+	handlers []*plugins.PluginHandler
+}
+
+// AddHandler Method definition from interface
+func (plugin *Moderation) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
+	pluginHandler := &plugins.PluginHandler{
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionGamePlay,
+	}
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
+	}
+	plugin.handlers = append(plugin.handlers, pluginHandler)
+}
+
+// Handlers Method definition from interface
+func (plugin *Moderation) Handlers() []*plugins.PluginHandler {
+	return plugin.handlers
+}
+
+// Name defines the name of the plugin
+func (plugin *Moderation) Name() string {
+	return plugin.name
+}
+
+// NewPlugin returns the new plugin
+func NewPlugin() *Moderation {
+	plugin := &Moderation{
+		name: PluginName,
+	}
+	return plugin
+}
+
+// Plugin returns an instance of the plugin
+func Plugin() *Moderation {
+	once.Do(func() {
+		plugin = NewPlugin()
+	})
+	return plugin
+}
+
+func init() {
+	moderation := Plugin()
+	moderation.AddHandler(http.MethodGet, "/blocks", listBlocks)
+	moderation.AddHandler(http.MethodPost, "/blocks/:username", blockUser)
+	moderation.AddHandler(http.MethodDelete, "/blocks/:username", unblockUser)
+	moderation.AddHandler(http.MethodPost, "/reports", createReport)
+	moderation.AddHandler(http.MethodGet, "/reports", listReports, rbac.PermissionUserManage)
+	moderation.AddHandler(http.MethodPost, "/reports/:id/review", reviewReport, rbac.PermissionUserManage)
+}
+
+// @Summary list the users blocked by the user
+// @Produce  application/json
+// @Router /moderation/blocks [get]
+// @Tags Moderation
+// @Success 200 {object} ListBlocksResponse
+func listBlocks(ctx echo.Context) error {
+	userID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ListBlocksResponse{
+			Error: err.Error(),
+		})
+	}
+	blocks, err := blockService.FindByBlocker(userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListBlocksResponse{
+			Error: err.Error(),
+		})
+	}
+
+	var ids []primitive.ObjectID
+	for _, b := range blocks {
+		ids = append(ids, b.BlockedID)
+	}
+	users := make(map[primitive.ObjectID]*models.User)
+	if len(ids) > 0 {
+		found, err := userService.Find(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ListBlocksResponse{
+				Error: err.Error(),
+			})
+		}
+		for _, u := range found {
+			users[u.ID] = u
+		}
+	}
+
+	res := ListBlocksResponse{
+		Blocks: []BlockResponse{},
+	}
+	for _, b := range blocks {
+		u, ok := users[b.BlockedID]
+		if !ok {
+			continue
+		}
+		res.Blocks = append(res.Blocks, BlockResponse{
+			Username:  u.Username,
+			CreatedAt: b.CreatedAt,
+		})
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary block a user
+// @Description Blocked users are not matched together, cannot challenge or befriend each other
+// @Description and cannot see each other's profile. Blocking removes the friendship.
+// @Produce  application/json
+// @Router /moderation/blocks/{username} [post]
+// @Tags Moderation
+// @Param username path string true "username"
+// @Success 200 {object} BlockResponse
+// @Failure 404 {object} BlockResponse
+func blockUser(ctx echo.Context) error {
+	userID, other, status, err := findOther(ctx)
+	if err != nil {
+		return ctx.JSON(status, BlockResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if err := blockService.Block(userID, other.ID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, BlockResponse{
+			Error: err.Error(),
+		})
+	}
+	friendship, err := friendshipService.FindBetween(userID, other.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, BlockResponse{
+			Error: err.Error(),
+		})
+	}
+	if friendship != nil {
+		if err := friendshipService.DeleteByID(friendship.ID); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, BlockResponse{
+				Error: err.Error(),
+			})
+		}
+	}
+	return ctx.JSON(http.StatusOK, BlockResponse{
+		Username:  other.Username,
+		CreatedAt: time.Now(),
+	})
+}
+
+// @Summary unblock a user
+// @Produce  application/json
+// @Router /moderation/blocks/{username} [delete]
+// @Tags Moderation
+// @Param username path string true "username"
+// @Success 200 {object} BlockResponse
+// @Failure 404 {object} BlockResponse
+func unblockUser(ctx echo.Context) error {
+	userID, other, status, err := findOther(ctx)
+	if err != nil {
+		return ctx.JSON(status, BlockResponse{
+			Error: err.Error(),
+		})
+	}
+
+	ok, err := blockService.Unblock(userID, other.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, BlockResponse{
+			Error: err.Error(),
+		})
+	}
+	if !ok {
+		return ctx.JSON(http.StatusNotFound, BlockResponse{
+			Error: ErrNotBlocked.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, BlockResponse{
+		Username: other.Username,
+	})
+}
+
+// findOther finds the user of the username path parameter, who must not be the user of the request
+func findOther(ctx echo.Context) (primitive.ObjectID, *models.User, int, error) {
+	userID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return userID, nil, http.StatusBadRequest, err
+	}
+	other := userService.FindByUsername(ctx.Param("username"))
+	if other.ID.IsZero() {
+		return userID, nil, http.StatusNotFound, ErrUserNotFound
+	}
+	if other.ID == userID {
+		return userID, nil, http.StatusBadRequest, ErrSelfBlock
+	}
+	return userID, other, http.StatusOK, nil
+}
+
+// BlockResponse represents a blocked user
+type BlockResponse struct {
+	Error     string    `json:"error,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+}
+
+// ListBlocksResponse represents the Response object for ListBlocks
+type ListBlocksResponse struct {
+	Error  string          `json:"error,omitempty"`
+	Blocks []BlockResponse `json:"blocks"`
+}
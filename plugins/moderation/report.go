@@ -0,0 +1,361 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/audit"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	reportService "github.com/acha-bill/quizzer_backend/packages/dblayer/report"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxReportDetailsLength = 1000
+	defaultReportsLimit    = 20
+	maxReportsLimit        = 100
+)
+
+var (
+	// ErrSelfReport is returned when a user reports themselves
+	ErrSelfReport = errors.New("you cannot report yourself")
+	// ErrInvalidReason is returned when the reason is not one of models.ReportReasons
+	ErrInvalidReason = errors.New("invalid report reason")
+	// ErrDetailsTooLong is returned when the details are longer than maxReportDetailsLength
+	ErrDetailsTooLong = fmt.Errorf("details must have at most %d characters", maxReportDetailsLength)
+	// ErrGameNotFound is returned when the reported game does not exist or was not played by both users
+	ErrGameNotFound = errors.New("game not found")
+	// ErrReportNotFound is returned when the report does not exist
+	ErrReportNotFound = errors.New("report not found")
+	// ErrReportReviewed is returned when reviewing a report that is not open
+	ErrReportReviewed = errors.New("report has already been reviewed")
+	// ErrInvalidStatus is returned when a report is reviewed with a status other than resolved or dismissed
+	ErrInvalidStatus = errors.New("status must be resolved or dismissed")
+	// ErrUnknownStatus is returned when listing reports with a status that does not exist
+	ErrUnknownStatus = errors.New("unknown report status")
+)
+
+// @Summary report a player
+// @Description gameId optionally references a game both players took part in.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /moderation/reports [post]
+// @Tags Moderation
+// @Param report body CreateReportRequest true "report"
+// @Success 201 {object} ReportResponse
+// @Failure 400 {object} ReportResponse
+// @Failure 404 {object} ReportResponse
+func createReport(ctx echo.Context) error {
+	var req CreateReportRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	if !models.IsValidReportReason(req.Reason) {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: ErrInvalidReason.Error(),
+		})
+	}
+	if len(req.Details) > maxReportDetailsLength {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: ErrDetailsTooLong.Error(),
+		})
+	}
+
+	reporterID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	reported := userService.FindByUsername(req.Username)
+	if reported.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, ReportResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if reported.ID == reporterID {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: ErrSelfReport.Error(),
+		})
+	}
+
+	var gameID *primitive.ObjectID
+	if req.GameID != "" {
+		id, err := primitive.ObjectIDFromHex(req.GameID)
+		if err != nil {
+			return ctx.JSON(http.StatusNotFound, ReportResponse{
+				Error: ErrGameNotFound.Error(),
+			})
+		}
+		game, err := gameResultService.FindById(id)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ReportResponse{
+				Error: err.Error(),
+			})
+		}
+		if game == nil || !playedIn(game, reporterID) || !playedIn(game, reported.ID) {
+			return ctx.JSON(http.StatusNotFound, ReportResponse{
+				Error: ErrGameNotFound.Error(),
+			})
+		}
+		gameID = &id
+	}
+
+	report, err := reportService.Create(models.Report{
+		ID:         primitive.NewObjectID(),
+		ReporterID: reporterID,
+		ReportedID: reported.ID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		GameID:     gameID,
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusCreated, newReportResponse(report, map[primitive.ObjectID]string{
+		reporterID:  common.GetUsername(ctx),
+		reported.ID: reported.Username,
+	}))
+}
+
+// @Summary list reports in the moderation queue, oldest first
+// @Produce  application/json
+// @Router /moderation/reports [get]
+// @Tags Moderation
+// @Param status query string false "open (default), resolved, dismissed or all"
+// @Param page query int false "page, starting at 1"
+// @Param limit query int false "reports per page, at most 100"
+// @Success 200 {object} ListReportsResponse
+func listReports(ctx echo.Context) error {
+	status := models.ReportStatus(ctx.QueryParam("status"))
+	switch status {
+	case "":
+		status = models.ReportOpen
+	case "all":
+		status = ""
+	case models.ReportOpen, models.ReportResolved, models.ReportDismissed:
+	default:
+		return ctx.JSON(http.StatusBadRequest, ListReportsResponse{
+			Error: ErrUnknownStatus.Error(),
+		})
+	}
+	page, limit, err := common.Pagination(ctx, defaultReportsLimit, maxReportsLimit)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ListReportsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	reports, err := reportService.FindByStatus(status, (page-1)*limit, limit)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListReportsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	ids := make(map[primitive.ObjectID]bool)
+	for _, r := range reports {
+		ids[r.ReporterID] = true
+		ids[r.ReportedID] = true
+		if r.ReviewerID != nil {
+			ids[*r.ReviewerID] = true
+		}
+	}
+	usernames, err := findUsernames(ids)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ListReportsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	res := ListReportsResponse{
+		Reports: []ReportResponse{},
+		Page:    page,
+		Limit:   limit,
+	}
+	for _, r := range reports {
+		res.Reports = append(res.Reports, newReportResponse(r, usernames))
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary close a report as resolved or dismissed
+// @Description Acting on the reported account is done separately with the suspend and ban endpoints of /admin.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /moderation/reports/{id}/review [post]
+// @Tags Moderation
+// @Param id path string true "report id"
+// @Param review body ReviewReportRequest true "review"
+// @Success 200 {object} ReportResponse
+// @Failure 404 {object} ReportResponse
+// @Failure 409 {object} ReportResponse
+func reviewReport(ctx echo.Context) error {
+	var req ReviewReportRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	if req.Status != models.ReportResolved && req.Status != models.ReportDismissed {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: ErrInvalidStatus.Error(),
+		})
+	}
+	reviewerID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, ReportResponse{
+			Error: ErrReportNotFound.Error(),
+		})
+	}
+	report, err := reportService.FindById(id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	if report == nil {
+		return ctx.JSON(http.StatusNotFound, ReportResponse{
+			Error: ErrReportNotFound.Error(),
+		})
+	}
+	if report.Status != models.ReportOpen {
+		return ctx.JSON(http.StatusConflict, ReportResponse{
+			Error: ErrReportReviewed.Error(),
+		})
+	}
+
+	if err := reportService.Review(id, req.Status, reviewerID, req.Note); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	if reported := userService.FindById(report.ReportedID.Hex()); !reported.ID.IsZero() {
+		audit.Record(ctx, audit.ActionReviewReport, reported, "%s report %s: %s", req.Status, report.ID.Hex(), req.Note)
+	}
+	now := time.Now()
+	report.Status = req.Status
+	report.ReviewerID = &reviewerID
+	report.ReviewNote = req.Note
+	report.ReviewedAt = &now
+	usernames, err := findUsernames(map[primitive.ObjectID]bool{
+		report.ReporterID: true,
+		report.ReportedID: true,
+		reviewerID:        true,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ReportResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newReportResponse(report, usernames))
+}
+
+// playedIn returns true if the user is one of the players of the game
+func playedIn(game *models.GameResult, userID primitive.ObjectID) bool {
+	for _, p := range game.Players {
+		if p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// findUsernames maps the ids of the users to their usernames
+func findUsernames(ids map[primitive.ObjectID]bool) (map[primitive.ObjectID]string, error) {
+	usernames := make(map[primitive.ObjectID]string)
+	if len(ids) == 0 {
+		return usernames, nil
+	}
+	var in []primitive.ObjectID
+	for id := range ids {
+		in = append(in, id)
+	}
+	users, err := userService.Find(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: in}}}})
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+	return usernames, nil
+}
+
+func newReportResponse(r *models.Report, usernames map[primitive.ObjectID]string) ReportResponse {
+	res := ReportResponse{
+		ID:         r.ID.Hex(),
+		Reporter:   usernames[r.ReporterID],
+		Reported:   usernames[r.ReportedID],
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ReviewNote: r.ReviewNote,
+		ReviewedAt: r.ReviewedAt,
+		CreatedAt:  r.CreatedAt,
+	}
+	if r.GameID != nil {
+		res.GameID = r.GameID.Hex()
+	}
+	if r.ReviewerID != nil {
+		res.Reviewer = usernames[*r.ReviewerID]
+	}
+	return res
+}
+
+// CreateReportRequest represents the Request object for CreateReport
+type CreateReportRequest struct {
+	Username string              `json:"username"`
+	Reason   models.ReportReason `json:"reason" enums:"cheating,harassment,offensive_name,spam,other"`
+	Details  string              `json:"details"`
+	GameID   string              `json:"gameId"`
+}
+
+// ReviewReportRequest represents the Request object for ReviewReport
+type ReviewReportRequest struct {
+	Status models.ReportStatus `json:"status" enums:"resolved,dismissed"`
+	Note   string              `json:"note"`
+}
+
+// ReportResponse represents a report in the moderation queue
+type ReportResponse struct {
+	Error      string              `json:"error,omitempty"`
+	ID         string              `json:"id,omitempty"`
+	Reporter   string              `json:"reporter,omitempty"`
+	Reported   string              `json:"reported,omitempty"`
+	Reason     models.ReportReason `json:"reason,omitempty"`
+	Details    string              `json:"details,omitempty"`
+	GameID     string              `json:"gameId,omitempty"`
+	Status     models.ReportStatus `json:"status,omitempty"`
+	Reviewer   string              `json:"reviewer,omitempty"`
+	ReviewNote string              `json:"reviewNote,omitempty"`
+	ReviewedAt *time.Time          `json:"reviewedAt,omitempty"`
+	CreatedAt  time.Time           `json:"createdAt"`
+}
+
+// ListReportsResponse represents the Response object for ListReports
+type ListReportsResponse struct {
+	Error   string           `json:"error,omitempty"`
+	Reports []ReportResponse `json:"reports"`
+	Page    int64            `json:"page,omitempty"`
+	Limit   int64            `json:"limit,omitempty"`
+}
@@ -1,29 +1,21 @@
 package plugins
 
 import (
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"github.com/labstack/echo/v4"
 )
 
 // PluginHandler represents the PluginHandler structure
 type PluginHandler struct {
-	Path      string
-	Handler   func(echo.Context) error
-	Method    string
-	AuthLevel AuthLevel
+	Path       string
+	Handler    func(echo.Context) error
+	Method     string
+	Permission rbac.Permission
 }
 
-// AuthLevel represents our Auth level enum
-type AuthLevel int
-
-const (
-	AuthLevelNone AuthLevel = iota
-	AuthLevelUser
-	AuthLevelAdmin
-)
-
 // Plugin interface
 type Plugin interface {
 	Name() string
-	AddHandler(method string, path string, handler func(echo.Context) error, authLevel ...AuthLevel)
+	AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission)
 	Handlers() []*PluginHandler
 }
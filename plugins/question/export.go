@@ -0,0 +1,218 @@
+package question
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	exportJSON = "json"
+	exportCSV  = "csv"
+	exportYAML = "yaml"
+)
+
+var (
+	// ErrUnknownExportFormat is returned when the export format is not json, csv or yaml
+	ErrUnknownExportFormat = errors.New("unknown format, expected json, csv or yaml")
+
+	exportContentTypes = map[string]string{
+		exportJSON: echo.MIMEApplicationJSONCharsetUTF8,
+		exportCSV:  "text/csv; charset=UTF-8",
+		exportYAML: "application/yaml; charset=UTF-8",
+	}
+	exportCSVHeader = []string{"id", "question", "type", "answers", "correctAnswer", "aliases", "category", "difficulty", "tags", "createdAt", "updatedAt"}
+)
+
+// @Summary export the questions
+// @Description The format is given by the format parameter, or else negotiated from the Accept header
+// @Description (application/json, text/csv or application/yaml) and defaults to JSON.
+// @Description Exports use the schema of the import, so that they can be imported back.
+// @Produce  json
+// @Produce  text/csv
+// @Produce  application/yaml
+// @Router /question/export [get]
+// @Tags Question
+// @Param format query string false "json, csv or yaml"
+// @Param category query []string false "category ids or names" collectionFormat(csv)
+// @Param difficulty query []string false "easy, medium or hard" collectionFormat(csv)
+// @Param tags query []string false "questions with any of the tags" collectionFormat(csv)
+// @Param from query string false "created at or after, RFC 3339"
+// @Param to query string false "created before, RFC 3339"
+// @Success 200 {array} ExportedQuestion
+// @Failure 400 {object} QuestionResponse
+func export(ctx echo.Context) error {
+	format := ctx.QueryParam("format")
+	if format == "" {
+		format = negotiateExportFormat(ctx.Request().Header.Get(echo.HeaderAccept))
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, QuestionResponse{
+			Error: ErrUnknownExportFormat.Error(),
+		})
+	}
+	filter, status, err := questionFilter(ctx)
+	if err != nil {
+		return ctx.JSON(status, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	categoryNames, err := categoryService.Names()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="questions.`+format+`"`)
+	res.WriteHeader(http.StatusOK)
+
+	// the status is sent, so an error can only cut the export short
+	if err := writeExport(res, format, filter, categoryNames); err != nil {
+		log.Errorf("question export: %v", err)
+	}
+	return nil
+}
+
+// negotiateExportFormat returns the first format of the Accept header that can be exported
+func negotiateExportFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch mediaType {
+		case echo.MIMEApplicationJSON:
+			return exportJSON
+		case "text/csv":
+			return exportCSV
+		case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+			return exportYAML
+		}
+	}
+	return exportJSON
+}
+
+// writeExport writes the questions matching the filter as they are read from the database
+func writeExport(res *echo.Response, format string, filter interface{}, categoryNames map[primitive.ObjectID]string) error {
+	switch format {
+	case exportCSV:
+		w := csv.NewWriter(res)
+		if err := w.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		err := questionService.Each(filter, func(q *models.Question) error {
+			e := newExportedQuestion(q, categoryNames)
+			// the correct answer column also holds the correct answers or the number, as the import reads them
+			correctAnswer := e.CorrectAnswer
+			if len(e.CorrectAnswers) > 0 {
+				correctAnswer = strings.Join(e.CorrectAnswers, "|")
+			}
+			if e.NumericAnswer != nil {
+				correctAnswer = strconv.FormatFloat(*e.NumericAnswer, 'f', -1, 64)
+			}
+			w.Write([]string{
+				e.ID,
+				e.Question,
+				string(e.Type),
+				strings.Join(e.Answers, "|"),
+				correctAnswer,
+				strings.Join(e.Aliases, "|"),
+				e.Category,
+				string(e.Difficulty),
+				strings.Join(e.Tags, "|"),
+				e.CreatedAt.Format(time.RFC3339),
+				e.UpdatedAt.Format(time.RFC3339),
+			})
+			w.Flush()
+			return w.Error()
+		})
+		w.Flush()
+		return err
+
+	case exportYAML:
+		// each question is written as a list of one, which add up to a single list
+		empty := true
+		err := questionService.Each(filter, func(q *models.Question) error {
+			empty = false
+			b, err := yaml.Marshal([]ExportedQuestion{newExportedQuestion(q, categoryNames)})
+			if err != nil {
+				return err
+			}
+			_, err = res.Write(b)
+			return err
+		})
+		if err == nil && empty {
+			_, err = io.WriteString(res, "[]\n")
+		}
+		return err
+	}
+
+	if _, err := io.WriteString(res, "["); err != nil {
+		return err
+	}
+	first := true
+	enc := json.NewEncoder(res)
+	err := questionService.Each(filter, func(q *models.Question) error {
+		if !first {
+			if _, err := io.WriteString(res, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(newExportedQuestion(q, categoryNames))
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(res, "]\n")
+	return err
+}
+
+func newExportedQuestion(q *models.Question, categoryNames map[primitive.ObjectID]string) ExportedQuestion {
+	return ExportedQuestion{
+		ID:             q.ID.Hex(),
+		Question:       q.Question,
+		Type:           q.Kind(),
+		Answers:        q.Answers,
+		CorrectAnswer:  q.CorrectAnswer,
+		CorrectAnswers: q.CorrectAnswers,
+		NumericAnswer:  q.NumericAnswer,
+		Aliases:        q.Aliases,
+		Category:       categoryNames[q.CategoryID],
+		Difficulty:     q.Level(),
+		Tags:           q.Tags,
+		CreatedAt:      q.CreatedAt,
+		UpdatedAt:      q.UpdatedAt,
+	}
+}
+
+// ExportedQuestion represents a question of an export
+type ExportedQuestion struct {
+	ID             string              `json:"id" yaml:"id"`
+	Question       string              `json:"question" yaml:"question"`
+	Type           models.QuestionType `json:"type" yaml:"type"`
+	Answers        []string            `json:"answers,omitempty" yaml:"answers,omitempty"`
+	CorrectAnswer  string              `json:"correctAnswer,omitempty" yaml:"correctAnswer,omitempty"`
+	CorrectAnswers []string            `json:"correctAnswers,omitempty" yaml:"correctAnswers,omitempty"`
+	NumericAnswer  *float64            `json:"numericAnswer,omitempty" yaml:"numericAnswer,omitempty"`
+	Aliases        []string            `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Category       string              `json:"category,omitempty" yaml:"category,omitempty"`
+	Difficulty     models.Difficulty   `json:"difficulty" yaml:"difficulty"`
+	Tags           []string            `json:"tags,omitempty" yaml:"tags,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt" yaml:"updatedAt"`
+}
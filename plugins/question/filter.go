@@ -0,0 +1,106 @@
+package question
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidDate is returned when the from or to filter is not an RFC 3339 date
+	ErrInvalidDate = errors.New("from and to must be RFC 3339 dates")
+)
+
+// questionFilter returns the filter selected by the query parameters, or an error and its status:
+// category keeps the questions of the categories given by id or name, difficulty those of the difficulties,
+// tags those with any of the tags, all repeated or separated by commas, and from and to bound the creation date.
+func questionFilter(ctx echo.Context) (bson.D, int, error) {
+	filter := bson.D{}
+	if values := ctx.QueryParams()["category"]; len(values) > 0 {
+		ids, err := categoryIDs(values)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		filter = append(filter, bson.E{Key: "categoryId", Value: bson.D{{Key: "$in", Value: ids}}})
+	}
+	if values := splitValues(ctx.QueryParams()["difficulty"]); len(values) > 0 {
+		difficulties := bson.A{}
+		for _, v := range values {
+			difficulty := models.Difficulty(strings.ToLower(v))
+			if !models.IsValidDifficulty(difficulty) {
+				return nil, http.StatusBadRequest, models.ErrInvalidDifficulty
+			}
+			difficulties = append(difficulties, difficulty)
+			if difficulty == models.DifficultyMedium {
+				difficulties = append(difficulties, nil)
+			}
+		}
+		filter = append(filter, bson.E{Key: "difficulty", Value: bson.D{{Key: "$in", Value: difficulties}}})
+	}
+
+	if tags := models.NormaliseTags(splitValues(ctx.QueryParams()["tags"])); len(tags) > 0 {
+		filter = append(filter, bson.E{Key: "tags", Value: bson.D{{Key: "$in", Value: tags}}})
+	}
+
+	created := bson.D{}
+	for _, bound := range []struct {
+		param string
+		op    string
+	}{
+		{"from", "$gte"},
+		{"to", "$lt"},
+	} {
+		v := ctx.QueryParam(bound.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, http.StatusBadRequest, ErrInvalidDate
+		}
+		created = append(created, bson.E{Key: bound.op, Value: t})
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: created})
+	}
+	return filter, http.StatusOK, nil
+}
+
+// categoryIDs returns the ids of the categories given by id or name. Unknown names are left out.
+func categoryIDs(values []string) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{}
+	for _, v := range splitValues(values) {
+		if id, err := primitive.ObjectIDFromHex(v); err == nil {
+			ids = append(ids, id)
+			continue
+		}
+		c, err := categoryService.FindByName(v)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids, nil
+}
+
+// splitValues returns the values of a repeated query parameter whose values may also be separated by commas
+func splitValues(values []string) []string {
+	var res []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				res = append(res, v)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,107 @@
+package question
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/acha-bill/quizzer_backend/packages/questionimport"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxImportSize = 10 << 20
+)
+
+var (
+	// ErrImportTooLarge is returned when the import file is larger than maxImportSize
+	ErrImportTooLarge = errors.New("import file must not be larger than 10MB")
+)
+
+// @Summary import questions from a file
+// @Description The file is sent as the body or as the "file" field of a multipart form. Its format is csv
+// @Description (columns question, answers separated by "|", correctAnswer and category), json (an array of
+// @Description questions) or opentdb (an Open Trivia DB response), and is guessed if not given.
+// @Description Imports are dry runs unless dryRun=false: every row is validated and reported, but nothing is saved.
+// @Description Categories are matched by name and missing ones are created. Duplicate questions are skipped.
+// @Accept  plain
+// @Produce  json
+// @Router /question/import [post]
+// @Tags Question
+// @Param format query string false "csv, json or opentdb"
+// @Param dryRun query bool false "dry run, true by default"
+// @Success 200 {object} ImportQuestionsResponse
+// @Failure 400 {object} ImportQuestionsResponse
+func importQuestions(ctx echo.Context) error {
+	dryRun := true
+	if v := ctx.QueryParam("dryRun"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, ImportQuestionsResponse{
+				Error: err.Error(),
+			})
+		}
+		dryRun = b
+	}
+	format := questionimport.Format(ctx.QueryParam("format"))
+	if format != "" && !questionimport.IsValidFormat(format) {
+		return ctx.JSON(http.StatusBadRequest, ImportQuestionsResponse{
+			Error: questionimport.ErrUnknownFormat.Error(),
+		})
+	}
+
+	filename, data, err := readImportFile(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ImportQuestionsResponse{
+			Error: err.Error(),
+		})
+	}
+	if format == "" {
+		format = questionimport.DetectFormat(filename, data)
+	}
+	rows, err := questionimport.Parse(format, data)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ImportQuestionsResponse{
+			Error: err.Error(),
+		})
+	}
+
+	result, err := questionimport.Import(rows, dryRun)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ImportQuestionsResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, ImportQuestionsResponse{
+		Format: format,
+		Result: result,
+	})
+}
+
+// readImportFile reads the "file" field of a multipart form, or else the body of the request
+func readImportFile(ctx echo.Context) (string, []byte, error) {
+	req := ctx.Request()
+	req.Body = http.MaxBytesReader(ctx.Response(), req.Body, maxImportSize)
+	if file, err := ctx.FormFile("file"); err == nil {
+		if file.Size > maxImportSize {
+			return "", nil, ErrImportTooLarge
+		}
+		f, err := file.Open()
+		if err != nil {
+			return "", nil, err
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		return file.Filename, data, err
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	return "", data, err
+}
+
+// ImportQuestionsResponse represents the Response object for ImportQuestions
+type ImportQuestionsResponse struct {
+	Error  string                 `json:"error,omitempty"`
+	Format questionimport.Format  `json:"format,omitempty"`
+	Result *questionimport.Result `json:"result,omitempty"`
+}
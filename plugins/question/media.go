@@ -0,0 +1,253 @@
+package question
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/blobstore"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// mediaField is the multipart field of the media
+	mediaField = "media"
+	// maxImageSize is the maximum size of an uploaded image in bytes
+	maxImageSize = 5 << 20
+	// maxAudioSize is the maximum size of an uploaded sound in bytes
+	maxAudioSize = 10 << 20
+	// mediaURLTTL is how long the media URLs of question responses can be used
+	mediaURLTTL = time.Hour
+)
+
+var (
+	// ErrMediaTooLarge is returned when an image is bigger than 5MB or a sound bigger than 10MB
+	ErrMediaTooLarge = errors.New("media is too large")
+	// ErrUnsupportedMedia is returned when the media is not a supported image or sound
+	ErrUnsupportedMedia = errors.New("media must be a PNG, JPEG, GIF or WebP image, or an MP3, Ogg or WAV sound")
+	// ErrNoMedia is returned when removing the media of a question that has none
+	ErrNoMedia = errors.New("question has no media")
+
+	// mediaFormats are the supported media by sniffed content type
+	mediaFormats = map[string]mediaFormat{
+		"image/png":       {models.MediaImage, "image/png", "png", maxImageSize},
+		"image/jpeg":      {models.MediaImage, "image/jpeg", "jpg", maxImageSize},
+		"image/gif":       {models.MediaImage, "image/gif", "gif", maxImageSize},
+		"image/webp":      {models.MediaImage, "image/webp", "webp", maxImageSize},
+		"audio/mpeg":      {models.MediaAudio, "audio/mpeg", "mp3", maxAudioSize},
+		"application/ogg": {models.MediaAudio, "audio/ogg", "ogg", maxAudioSize},
+		"audio/wave":      {models.MediaAudio, "audio/wav", "wav", maxAudioSize},
+	}
+)
+
+// mediaFormat is a supported media file
+type mediaFormat struct {
+	mediaType   models.MediaType
+	contentType string
+	ext         string
+	maxSize     int
+}
+
+// @Summary attach an image or a sound to a question
+// @Description The type of the file is read from its content. Images are PNG, JPEG, GIF or WebP files of at most 5MB,
+// @Description sounds are MP3, Ogg or WAV files of at most 10MB. The media replaces the one the question had.
+// @Description Media are only served at signed URLs, which expire after an hour.
+// @Accept  multipart/form-data
+// @Produce  application/json
+// @Router /question/{id}/media [post]
+// @Tags Question
+// @Param id path string true "question id"
+// @Param media formData file true "image or sound"
+// @Success 200 {object} QuestionResponse
+// @Failure 404 {object} QuestionResponse
+// @Failure 413 {object} QuestionResponse
+// @Failure 415 {object} QuestionResponse
+func uploadMedia(ctx echo.Context) error {
+	q, err := questionService.FindById(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if q == nil {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
+	}
+
+	// leave room for the multipart headers
+	ctx.Request().Body = http.MaxBytesReader(ctx.Response(), ctx.Request().Body, maxAudioSize+1<<20)
+	fileHeader, err := ctx.FormFile(mediaField)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if fileHeader.Size > maxAudioSize {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, QuestionResponse{
+			Error: ErrMediaTooLarge.Error(),
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(file, maxAudioSize+1))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+
+	format, ok := mediaFormats[sniffMedia(data)]
+	if !ok {
+		return ctx.JSON(http.StatusUnsupportedMediaType, QuestionResponse{
+			Error: ErrUnsupportedMedia.Error(),
+		})
+	}
+	if len(data) > format.maxSize {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, QuestionResponse{
+			Error: ErrMediaTooLarge.Error(),
+		})
+	}
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	media := &models.Media{
+		Type:        format.mediaType,
+		Key:         blobstore.PrivatePrefix + "questions/" + q.ID.Hex() + "-" + suffix + "." + format.ext,
+		ContentType: format.contentType,
+		Size:        int64(len(data)),
+	}
+	if _, err := blobstore.Instance().Put(media.Key, bytes.NewReader(data), media.ContentType); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := questionService.SetMedia(q.ID, media); err != nil {
+		deleteMedia(media)
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	deleteMedia(q.Media)
+	q.Media = media
+
+	category, err := categoryService.FindById(q.CategoryID.Hex())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newQuestionResponse(q, categoryName(category)))
+}
+
+// @Summary remove the image or sound of a question
+// @Produce  json
+// @Router /question/{id}/media [delete]
+// @Tags Question
+// @Param id path string true "question id"
+// @Success 200 {object} QuestionResponse
+// @Failure 404 {object} QuestionResponse
+func removeMedia(ctx echo.Context) error {
+	q, err := questionService.FindById(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if q == nil {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
+	}
+	if q.Media == nil {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrNoMedia.Error(),
+		})
+	}
+
+	if err := questionService.SetMedia(q.ID, nil); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	deleteMedia(q.Media)
+	q.Media = nil
+
+	category, err := categoryService.FindById(q.CategoryID.Hex())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newQuestionResponse(q, categoryName(category)))
+}
+
+// sniffMedia returns the content type of the data. MP3 files without an ID3 tag start with a frame
+// header, which http.DetectContentType does not recognise.
+func sniffMedia(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if contentType == "application/octet-stream" && len(data) > 1 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 {
+		return "audio/mpeg"
+	}
+	return contentType
+}
+
+// deleteMedia removes the file of the media from the blob store, if there is one
+func deleteMedia(media *models.Media) {
+	if media == nil {
+		return
+	}
+	if err := blobstore.Instance().Delete(media.Key); err != nil {
+		log.Errorf("deleting media %s: %v", media.Key, err)
+	}
+}
+
+// newMediaResponse returns the media with a signed URL. The URL is left out if it cannot be signed.
+func newMediaResponse(media *models.Media) *MediaResponse {
+	res := &MediaResponse{
+		Type:        media.Type,
+		ContentType: media.ContentType,
+		Size:        media.Size,
+	}
+	mediaURL, err := blobstore.Instance().SignedURL(media.Key, mediaURLTTL)
+	if err != nil {
+		log.Errorf("signing media %s: %v", media.Key, err)
+	}
+	res.URL = mediaURL
+	return res
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MediaResponse represents the image or sound of a question
+type MediaResponse struct {
+	Type        models.MediaType `json:"type"`
+	ContentType string           `json:"contentType"`
+	Size        int64            `json:"size"`
+	// URL is signed and expires after an hour
+	URL string `json:"url,omitempty"`
+}
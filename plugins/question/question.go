@@ -1,13 +1,16 @@
 package question
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/acha-bill/quizzer_backend/common"
 	"github.com/acha-bill/quizzer_backend/models"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
 	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"github.com/acha-bill/quizzer_backend/plugins"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,6 +23,10 @@ const (
 var (
 	plugin *Question
 	once   sync.Once
+	// ErrQuestionNotFound is returned when the question does not exist
+	ErrQuestionNotFound = errors.New("question not found")
+	// ErrCategoryNotFound is returned when the category of the question does not exist
+	ErrCategoryNotFound = errors.New("category not found")
 )
 
 type Question struct {
@@ -27,15 +34,15 @@ type Question struct {
 	handlers []*plugins.PluginHandler
 }
 
-func (plugin *Question) AddHandler(method string, path string, handler func(echo.Context) error, authLevel ...plugins.AuthLevel) {
+func (plugin *Question) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
 	pluginHandler := &plugins.PluginHandler{
-		Path:      path,
-		Handler:   handler,
-		Method:    method,
-		AuthLevel: plugins.AuthLevelUser,
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionQuestionWrite,
 	}
-	if len(authLevel) > 0 {
-		pluginHandler.AuthLevel = authLevel[0]
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
 	}
 	plugin.handlers = append(plugin.handlers, pluginHandler)
 }
@@ -65,11 +72,14 @@ func Plugin() *Question {
 func init() {
 	auth := Plugin()
 	auth.AddHandler(http.MethodPost, "/", create)
-	auth.AddHandler(http.MethodGet, "/", find)
-	// TODO: add these
-	//auth.AddHandler(http.MethodPut, "/:id", edit)
-	//auth.AddHandler(http.MethodDelete, "/:id", find)
-
+	auth.AddHandler(http.MethodPost, "/import", importQuestions, rbac.PermissionCategoryWrite)
+	auth.AddHandler(http.MethodGet, "/", find, rbac.PermissionQuestionRead)
+	auth.AddHandler(http.MethodGet, "/export", export, rbac.PermissionQuestionRead)
+	auth.AddHandler(http.MethodGet, "/:id", findOne, rbac.PermissionQuestionRead)
+	auth.AddHandler(http.MethodPut, "/:id", edit)
+	auth.AddHandler(http.MethodDelete, "/:id", remove)
+	auth.AddHandler(http.MethodPost, "/:id/media", uploadMedia)
+	auth.AddHandler(http.MethodDelete, "/:id/media", removeMedia)
 }
 
 // @Summary list all questions
@@ -77,75 +87,338 @@ func init() {
 // @Produce  json
 // @Router /question/ [get]
 // @Tags Question
-// @Success 201 {object} FindQuestionsResponse
+// @Param category query []string false "category ids or names" collectionFormat(csv)
+// @Param difficulty query []string false "easy, medium or hard" collectionFormat(csv)
+// @Param tags query []string false "questions with any of the tags" collectionFormat(csv)
+// @Param from query string false "created at or after, RFC 3339"
+// @Param to query string false "created before, RFC 3339"
+// @Success 200 {object} FindQuestionsResponse
+// @Failure 400 {object} FindQuestionsResponse
 func find(ctx echo.Context) error {
-	if !common.IsAdmin(ctx) {
-		return ctx.JSON(http.StatusUnauthorized, FindQuestionsResponse{
-			Error: "Unauthorized",
+	filter, status, err := questionFilter(ctx)
+	if err != nil {
+		return ctx.JSON(status, FindQuestionsResponse{
+			Error: err.Error(),
 		})
 	}
-	qs, err := questionService.FindAll()
+	qs, err := questionService.Find(filter)
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, FindQuestionsResponse{
+		return ctx.JSON(http.StatusInternalServerError, FindQuestionsResponse{
 			Error: err.Error(),
 		})
 	}
-	return ctx.JSON(http.StatusBadRequest, FindQuestionsResponse{
-		Questions: qs,
-	})
+	categoryNames, err := categoryService.Names()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, FindQuestionsResponse{
+			Error: err.Error(),
+		})
+	}
+	res := FindQuestionsResponse{
+		Questions: []QuestionResponse{},
+	}
+	for _, q := range qs {
+		res.Questions = append(res.Questions, newQuestionResponse(q, categoryNames[q.CategoryID]))
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary get a question
+// @Produce  json
+// @Router /question/{id} [get]
+// @Tags Question
+// @Param id path string true "question id"
+// @Success 200 {object} QuestionResponse
+// @Failure 404 {object} QuestionResponse
+func findOne(ctx echo.Context) error {
+	q, err := questionService.FindById(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if q == nil {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
+	}
+	category, err := categoryService.FindById(q.CategoryID.Hex())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newQuestionResponse(q, categoryName(category)))
 }
 
 // @Summary create question
+// @Description The question and answers must not be empty, there must be at least two distinct answers
+// @Description and the correct answer must be one of them. The category is optional but must exist.
+// @Description The difficulty is easy, medium or hard and defaults to medium. Harder questions score more.
+// @Description Multi-select questions have correctAnswers among the answers, numeric questions a numericAnswer,
+// @Description and free text questions a correctAnswer with optional aliases but no answers.
 // @Accept  json
 // @Produce  json
 // @Router /question/ [post]
 // @Tags Question
 // @Param question body CreateQuestionRequest true "create"
-// @Success 201 {object} CreateQuestionResponse
+// @Success 201 {object} QuestionResponse
+// @Failure 422 {object} QuestionResponse
 func create(ctx echo.Context) error {
-	if !common.IsAdmin(ctx) {
-		return ctx.JSON(http.StatusUnauthorized, CreateQuestionErrorResponse{
-			Error: "Unauthorized",
+	var req CreateQuestionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, QuestionResponse{
+			Error: err.Error(),
 		})
 	}
+
+	category, status, err := req.category()
+	if err != nil {
+		return ctx.JSON(status, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	now := time.Now()
+	q := req.question()
+	q.ID = primitive.NewObjectID()
+	q.CreatedAt = now
+	q.UpdatedAt = now
+	if category != nil {
+		q.CategoryID = category.ID
+	}
+	if err := q.Validate(); err != nil {
+		return ctx.JSON(http.StatusUnprocessableEntity, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+
+	created, err := questionService.Create(q)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusCreated, newQuestionResponse(created, categoryName(category)))
+}
+
+// @Summary edit a question
+// @Description The question is replaced and validated like a new one. Its media is kept.
+// @Accept  json
+// @Produce  json
+// @Router /question/{id} [put]
+// @Tags Question
+// @Param id path string true "question id"
+// @Param question body CreateQuestionRequest true "edit"
+// @Success 200 {object} QuestionResponse
+// @Failure 404 {object} QuestionResponse
+// @Failure 422 {object} QuestionResponse
+func edit(ctx echo.Context) error {
 	var req CreateQuestionRequest
 	if err := ctx.Bind(&req); err != nil {
-		return ctx.JSON(http.StatusBadRequest, CreateQuestionErrorResponse{
+		return ctx.JSON(http.StatusBadRequest, QuestionResponse{
 			Error: err.Error(),
 		})
 	}
 
-	q := models.Question{
-		ID:            primitive.NewObjectID(),
-		Question:      req.Question,
-		Answers:       req.Answers,
-		CorrectAnswer: req.CorrectAnswer,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	existing, err := questionService.FindById(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if existing == nil {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
 	}
 
-	created, err := questionService.Create(q)
+	category, status, err := req.category()
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, CreateQuestionErrorResponse{
+		return ctx.JSON(status, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	q := req.question()
+	q.ID = existing.ID
+	q.CreatedAt = existing.CreatedAt
+	q.UpdatedAt = time.Now()
+	q.Media = existing.Media
+	if category != nil {
+		q.CategoryID = category.ID
+	}
+	if err := q.Validate(); err != nil {
+		return ctx.JSON(http.StatusUnprocessableEntity, QuestionResponse{
 			Error: err.Error(),
 		})
 	}
 
-	return ctx.JSON(http.StatusBadRequest, created)
+	err = questionService.UpdateById(existing.ID.Hex(), q)
+	if err == questionService.ErrNoQuestionUpdated {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newQuestionResponse(&q, categoryName(category)))
 }
 
+// @Summary delete a question
+// @Description The media of the question is deleted with it.
+// @Produce  json
+// @Router /question/{id} [delete]
+// @Tags Question
+// @Param id path string true "question id"
+// @Success 200 {object} QuestionResponse
+// @Failure 404 {object} QuestionResponse
+func remove(ctx echo.Context) error {
+	q, err := questionService.FindById(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	if q == nil {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
+	}
+	err = questionService.DeleteById(q.ID.Hex())
+	if err == questionService.ErrNoQuestionDeleted {
+		return ctx.JSON(http.StatusNotFound, QuestionResponse{
+			Error: ErrQuestionNotFound.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, QuestionResponse{
+			Error: err.Error(),
+		})
+	}
+	deleteMedia(q.Media)
+	return ctx.JSON(http.StatusOK, QuestionResponse{
+		ID: q.ID.Hex(),
+	})
+}
+
+func newQuestionResponse(q *models.Question, categoryName string) QuestionResponse {
+	res := QuestionResponse{
+		ID:             q.ID.Hex(),
+		Question:       q.Question,
+		Type:           q.Kind(),
+		Answers:        q.Answers,
+		CorrectAnswer:  q.CorrectAnswer,
+		CorrectAnswers: q.CorrectAnswers,
+		NumericAnswer:  q.NumericAnswer,
+		Aliases:        q.Aliases,
+		Difficulty:     q.Level(),
+		Tags:           q.Tags,
+		Category:       categoryName,
+		CreatedAt:      q.CreatedAt,
+		UpdatedAt:      q.UpdatedAt,
+	}
+	if !q.CategoryID.IsZero() {
+		res.CategoryID = q.CategoryID.Hex()
+	}
+	if q.Media != nil {
+		res.Media = newMediaResponse(q.Media)
+	}
+	return res
+}
+
+func categoryName(category *models.Category) string {
+	if category == nil {
+		return ""
+	}
+	return category.Name
+}
+
+// CreateQuestionRequest represents the Request object for Create and Edit
 type CreateQuestionRequest struct {
-	Question      string   `json:"question"`
-	Answers       []string `json:"answers"`
-	CorrectAnswer string   `json:"correctAnswer"`
+	Question string `json:"question"`
+	// Type is single (the default), true_false, multi, numeric or text
+	Type models.QuestionType `json:"type,omitempty"`
+	// Answers are the options of single choice, true/false and multi-select questions.
+	// They default to True and False for true/false questions.
+	Answers []string `json:"answers"`
+	// CorrectAnswer is the answer of single choice, true/false and free text questions
+	CorrectAnswer string `json:"correctAnswer"`
+	// CorrectAnswers are the correct options of a multi-select question
+	CorrectAnswers []string `json:"correctAnswers,omitempty"`
+	// NumericAnswer is the answer of a numeric question
+	NumericAnswer *float64 `json:"numericAnswer,omitempty"`
+	// Aliases are other accepted answers of a free text question
+	Aliases    []string `json:"aliases,omitempty"`
+	CategoryID string   `json:"categoryId,omitempty"`
+	// Difficulty is easy, medium or hard. Questions without one are medium.
+	Difficulty models.Difficulty `json:"difficulty,omitempty"`
+	// Tags are stored lower cased
+	Tags []string `json:"tags,omitempty"`
+}
+
+// question returns the question of the request with surrounding spaces removed
+func (req *CreateQuestionRequest) question() models.Question {
+	q := models.Question{
+		Question:       strings.TrimSpace(req.Question),
+		Type:           models.QuestionType(strings.ToLower(strings.TrimSpace(string(req.Type)))),
+		Answers:        trimAll(req.Answers),
+		CorrectAnswer:  strings.TrimSpace(req.CorrectAnswer),
+		CorrectAnswers: trimAll(req.CorrectAnswers),
+		NumericAnswer:  req.NumericAnswer,
+		Aliases:        trimAll(req.Aliases),
+		Difficulty:     models.Difficulty(strings.ToLower(strings.TrimSpace(string(req.Difficulty)))),
+		Tags:           req.Tags,
+	}
+	q.Normalise()
+	return q
+}
+
+func trimAll(values []string) []string {
+	var res []string
+	for _, v := range values {
+		res = append(res, strings.TrimSpace(v))
+	}
+	return res
+}
+
+// category finds the category of the request. It returns nil if the request has none.
+func (req *CreateQuestionRequest) category() (*models.Category, int, error) {
+	if req.CategoryID == "" {
+		return nil, http.StatusOK, nil
+	}
+	category, err := categoryService.FindById(req.CategoryID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if category == nil {
+		return nil, http.StatusUnprocessableEntity, ErrCategoryNotFound
+	}
+	return category, http.StatusOK, nil
 }
 
-type CreateQuestionResponse models.Question
-type CreateQuestionErrorResponse struct {
-	Error string `json:"error"`
+// QuestionResponse represents a question
+type QuestionResponse struct {
+	Error          string              `json:"error,omitempty"`
+	ID             string              `json:"id,omitempty"`
+	Question       string              `json:"question,omitempty"`
+	Type           models.QuestionType `json:"type,omitempty"`
+	Answers        []string            `json:"answers,omitempty"`
+	CorrectAnswer  string              `json:"correctAnswer,omitempty"`
+	CorrectAnswers []string            `json:"correctAnswers,omitempty"`
+	NumericAnswer  *float64            `json:"numericAnswer,omitempty"`
+	Aliases        []string            `json:"aliases,omitempty"`
+	Difficulty     models.Difficulty   `json:"difficulty,omitempty"`
+	Tags           []string            `json:"tags,omitempty"`
+	CategoryID     string              `json:"categoryId,omitempty"`
+	Category       string              `json:"category,omitempty"`
+	Media          *MediaResponse      `json:"media,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt,omitempty"`
+	UpdatedAt      time.Time           `json:"updatedAt,omitempty"`
 }
 
+// FindQuestionsResponse represents the Response object for Find
 type FindQuestionsResponse struct {
 	Error     string             `json:"error,omitempty"`
-	Questions []*models.Question `json:"questions"`
+	Questions []QuestionResponse `json:"questions"`
 }
@@ -3,13 +3,19 @@ package search
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/acha-bill/quizzer_backend/common"
+	categoryService "github.com/acha-bill/quizzer_backend/packages/dblayer/category"
+	questionService "github.com/acha-bill/quizzer_backend/packages/dblayer/question"
 	"github.com/acha-bill/quizzer_backend/packages/socketserver"
 
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
 	"github.com/acha-bill/quizzer_backend/plugins"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
@@ -21,11 +27,13 @@ var (
 	plugin              *Search
 	once                sync.Once
 	ErrUserNotConnected = errors.New("user not connected")
+	// ErrUnknownCategory is returned when a category of the search does not exist
+	ErrUnknownCategory = errors.New("unknown category")
 )
 
 const (
 	// GameLength defines the number of questions in a game
-	GameLength = 10
+	GameLength = socketserver.DefaultGameLength
 )
 
 // Search structure
@@ -35,15 +43,15 @@ type Search struct {
 }
 
 // AddHandler Method definition from interface
-func (plugin *Search) AddHandler(method string, path string, handler func(echo.Context) error, authLevel ...plugins.AuthLevel) {
+func (plugin *Search) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
 	pluginHandler := &plugins.PluginHandler{
-		Path:      path,
-		Handler:   handler,
-		Method:    method,
-		AuthLevel: plugins.AuthLevelUser,
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionGamePlay,
 	}
-	if len(authLevel) > 0 {
-		pluginHandler.AuthLevel = authLevel[0]
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
 	}
 	plugin.handlers = append(plugin.handlers, pluginHandler)
 }
@@ -80,12 +88,24 @@ func init() {
 }
 
 // @Summary search for a random opponent
+// @Description Players are only matched with players who want a category in common, or any category.
+// @Description The questions of the game are drawn from the categories they share, or from the categories of both
+// @Description players if those they share hold too few questions.
 // @Accept json
 // @produce json
 // @Router /search [get]
 // @Tags Search
+// @Param category query []string false "category ids, any category if empty" collectionFormat(csv)
 // @Success 200 {object} SearchOpponentResponse
+// @Failure 400 {object} SearchOpponentResponse
 func findOpponent(ctx echo.Context) error {
+	categories, err := searchCategories(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, SearchOpponentResponse{
+			Error: err.Error(),
+		})
+	}
+
 	gameMgr := socketserver.GameManager()
 	serverMgr := socketserver.ServerManager()
 	username := common.GetUsername(ctx)
@@ -95,25 +115,77 @@ func findOpponent(ctx echo.Context) error {
 			Error: ErrUserNotConnected.Error(),
 		})
 	}
-	err := gameMgr.AddSearcher(wsConn)
+	err = gameMgr.AddSearcher(wsConn, categories)
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, SearchOpponentResponse{
 			Error: err.Error(),
 		})
 	}
 
-	player1, player2 := gameMgr.GetPair()
+	player1, player2, gameCategories := gameMgr.GetPair(GameLength)
 	if player1 != nil && player2 != nil {
-		if err := gameMgr.NewGame(player1, player2, GameLength); err != nil {
-			return ctx.JSON(http.StatusBadRequest, SearchOpponentResponse{
-				Error: err.Error(),
-			})
+		if err := gameMgr.NewGame(player1, player2, GameLength, gameCategories); err != nil {
+			// the players left the queue, so those who were waiting are told their search ended
+			for _, player := range []*socketserver.WsConnection{player1, player2} {
+				if player != wsConn {
+					serverMgr.WriteConnection(player, socketserver.SocketResponseError{Error: err.Error()})
+				}
+			}
+			if player1 == wsConn || player2 == wsConn {
+				return ctx.JSON(http.StatusBadRequest, SearchOpponentResponse{
+					Error: err.Error(),
+				})
+			}
 		}
 	}
 
 	return ctx.JSON(http.StatusOK, SearchOpponentResponse{})
 }
 
+// searchCategories returns the categories of the category query parameter, repeated or separated by commas.
+// Every category must exist and together they must have enough questions for a game.
+func searchCategories(ctx echo.Context) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, value := range ctx.QueryParams()["category"] {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			id, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				return nil, ErrUnknownCategory
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	categories, err := categoryService.FindByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[primitive.ObjectID]bool)
+	for _, c := range categories {
+		found[c.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, ErrUnknownCategory
+		}
+	}
+	count, err := questionService.Count(bson.D{{Key: "categoryId", Value: bson.D{{Key: "$in", Value: ids}}}})
+	if err != nil {
+		return nil, err
+	}
+	if count < GameLength {
+		return nil, socketserver.ErrNotEnoughQuestions
+	}
+	return ids, nil
+}
+
 // SearchOpponentResponse represents the Search Response
 type SearchOpponentResponse struct {
 	Error string `json:"error,omitempty"`
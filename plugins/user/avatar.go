@@ -0,0 +1,212 @@
+package user
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	"github.com/acha-bill/quizzer_backend/packages/blobstore"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// avatarField is the multipart field of the avatar
+	avatarField = "avatar"
+	// maxAvatarSize is the maximum size of an uploaded avatar in bytes
+	maxAvatarSize = 5 << 20
+	// maxAvatarPixels is the maximum number of pixels of an uploaded avatar
+	maxAvatarPixels = 16 << 20
+	// avatarSize is the width and height of stored avatars
+	avatarSize = 256
+)
+
+var (
+	// ErrAvatarTooLarge is returned when the avatar is bigger than maxAvatarSize or maxAvatarPixels
+	ErrAvatarTooLarge = errors.New("avatar is too large")
+	// ErrUnsupportedImage is returned when the avatar is not a PNG, JPEG or GIF image
+	ErrUnsupportedImage = errors.New("avatar must be a PNG, JPEG or GIF image")
+
+	avatarTypes = map[string]bool{
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/gif":  true,
+	}
+)
+
+// @Summary upload the avatar of the user
+// @Description The image is cropped to a square and resized to 256x256 pixels.
+// @Accept  multipart/form-data
+// @Produce  application/json
+// @Router /user/me/avatar [post]
+// @Tags User
+// @Param avatar formData file true "PNG, JPEG or GIF image of at most 5MB"
+// @Success 200 {object} ProfileResponse
+// @Failure 413 {object} ProfileResponse
+// @Failure 415 {object} ProfileResponse
+func uploadAvatar(ctx echo.Context) error {
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, ProfileResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+
+	// leave room for the multipart headers
+	ctx.Request().Body = http.MaxBytesReader(ctx.Response(), ctx.Request().Body, maxAvatarSize+1<<20)
+	fileHeader, err := ctx.FormFile(avatarField)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	if fileHeader.Size > maxAvatarSize {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, ProfileResponse{
+			Error: ErrAvatarTooLarge.Error(),
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	if len(data) > maxAvatarSize {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, ProfileResponse{
+			Error: ErrAvatarTooLarge.Error(),
+		})
+	}
+
+	avatar, status, err := processAvatar(data)
+	if err != nil {
+		return ctx.JSON(status, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	key := "avatars/" + u.ID.Hex() + "-" + suffix + ".png"
+	profileURL, err := blobstore.Instance().Put(key, bytes.NewReader(avatar), "image/png")
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := setAvatar(u, profileURL); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, newProfileResponse(u))
+}
+
+// setAvatar sets the profile URL of the user and deletes the avatar it replaces from the blob store
+func setAvatar(u *models.User, profileURL string) error {
+	if err := userService.SetProfileURL(u.ID, profileURL); err != nil {
+		return err
+	}
+	old := u.ProfileURL
+	u.ProfileURL = profileURL
+	if key, ok := blobstore.Instance().Key(old); ok {
+		if err := blobstore.Instance().Delete(key); err != nil {
+			log.Errorf("deleting avatar %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// processAvatar checks that data is a supported image that is not too large,
+// then crops it to a square and resizes it. It returns the PNG encoded avatar.
+func processAvatar(data []byte) ([]byte, int, error) {
+	if !avatarTypes[http.DetectContentType(data)] {
+		return nil, http.StatusUnsupportedMediaType, ErrUnsupportedImage
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxAvatarPixels {
+		return nil, http.StatusRequestEntityTooLarge, ErrAvatarTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, ErrUnsupportedImage
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resizeSquare(img, avatarSize)); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return buf.Bytes(), http.StatusOK, nil
+}
+
+// resizeSquare crops the center square of the image and scales it down to size pixels
+// by averaging the pixels covered by each destination pixel. Smaller images are not scaled up.
+func resizeSquare(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	if side < size {
+		size = side
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		sy0, sy1 := y0+dy*side/size, y0+(dy+1)*side/size
+		for dx := 0; dx < size; dx++ {
+			sx0, sx1 := x0+dx*side/size, x0+(dx+1)*side/size
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
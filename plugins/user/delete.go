@@ -0,0 +1,141 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	friendshipService "github.com/acha-bill/quizzer_backend/packages/dblayer/friendship"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/packages/socketserver"
+	"github.com/acha-bill/quizzer_backend/packages/token"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrWrongPassword is returned when the password confirming the deletion is wrong
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrLastAdmin is returned when the only admin deletes their account
+	ErrLastAdmin = errors.New("the last admin cannot delete their account")
+	// ErrInGame is returned when deleting the account during a game
+	ErrInGame = errors.New("finish or leave your game first")
+)
+
+// @Summary delete the account of the user
+// @Description Accounts with a password must confirm it. The user is logged out everywhere, their profile,
+// @Description avatar, sessions, friends and blocks are deleted, and their name in game history is replaced
+// @Description with a tombstone so that the statistics of other players stay the same.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /user/me [delete]
+// @Tags User
+// @Param confirm body DeleteAccountRequest true "confirm"
+// @Success 200 {object} DeleteAccountResponse
+// @Failure 401 {object} DeleteAccountResponse
+// @Failure 409 {object} DeleteAccountResponse
+func deleteAccount(ctx echo.Context) error {
+	var req DeleteAccountRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, DeleteAccountResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, DeleteAccountResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	if u.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password)); err != nil {
+			return ctx.JSON(http.StatusUnauthorized, DeleteAccountResponse{
+				Error: ErrWrongPassword.Error(),
+			})
+		}
+	}
+	if u.Role == rbac.RoleAdmin {
+		admins, err := userService.CountByRole(rbac.RoleAdmin)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+				Error: err.Error(),
+			})
+		}
+		if admins <= 1 {
+			return ctx.JSON(http.StatusConflict, DeleteAccountResponse{
+				Error: ErrLastAdmin.Error(),
+			})
+		}
+	}
+	for _, wsConn := range socketserver.ServerManager().UserConnections(u.ID) {
+		if game := socketserver.GameManager().FindPlayerGame(wsConn); game != nil && game.IsActive() {
+			return ctx.JSON(http.StatusConflict, DeleteAccountResponse{
+				Error: ErrInGame.Error(),
+			})
+		}
+	}
+
+	if err := removeAccount(u); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Error: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, DeleteAccountResponse{
+		Deleted: true,
+	})
+}
+
+// removeAccount deletes the user and everything linked to them, except their game history
+// which is kept under a tombstone name.
+// The user document goes last so that a failed deletion can be retried.
+func removeAccount(u *models.User) error {
+	if err := token.Forget(u.ID); err != nil {
+		return err
+	}
+	socketserver.ServerManager().CloseUser(u.ID)
+
+	tombstone, err := tombstoneName()
+	if err != nil {
+		return err
+	}
+	if err := gameResultService.Anonymise(u.ID, u.Username, tombstone); err != nil {
+		return err
+	}
+	if err := friendshipService.DeleteByUser(u.ID); err != nil {
+		return err
+	}
+	if err := blockService.DeleteByUser(u.ID); err != nil {
+		return err
+	}
+	if err := setAvatar(u, ""); err != nil {
+		return err
+	}
+	return userService.DeleteByID(u.ID.Hex())
+}
+
+// tombstoneName returns a random name that replaces the username of a deleted user
+func tombstoneName() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return models.DeletedUsernamePrefix + hex.EncodeToString(b), nil
+}
+
+// DeleteAccountRequest represents the Request object for DeleteAccount
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteAccountResponse represents the Response object for DeleteAccount
+type DeleteAccountResponse struct {
+	Error   string `json:"error,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
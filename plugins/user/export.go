@@ -0,0 +1,392 @@
+package user
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	friendshipService "github.com/acha-bill/quizzer_backend/packages/dblayer/friendship"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	reportService "github.com/acha-bill/quizzer_backend/packages/dblayer/report"
+	sessionService "github.com/acha-bill/quizzer_backend/packages/dblayer/session"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// @Summary export everything stored about the user
+// @Description Returns a zip archive with one JSON file per section: profile, sessions, friends, blocks, games and reports.
+// @Description Use format=json to get a single JSON document instead.
+// @Produce  application/zip
+// @Produce  application/json
+// @Router /user/me/export [get]
+// @Tags User
+// @Param format query string false "zip (default) or json"
+// @Success 200 {object} DataExport
+func exportData(ctx echo.Context) error {
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, ProfileResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+
+	export, err := collectData(u)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	if ctx.QueryParam("format") == "json" {
+		return ctx.JSON(http.StatusOK, export)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"sessions.json", export.Sessions},
+		{"friends.json", export.Friends},
+		{"blocks.json", export.Blocks},
+		{"games.json", export.Games},
+		{"reports.json", export.Reports},
+	} {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+				Error: err.Error(),
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+				Error: err.Error(),
+			})
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("quizzer-%s-%s.zip", u.Username, export.ExportedAt.Format("20060102"))
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return ctx.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// collectData gathers everything stored about the user.
+// Secrets such as the password hash, the two-factor secret and token hashes are left out.
+func collectData(u *models.User) (*DataExport, error) {
+	export := &DataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			Username:         u.Username,
+			DisplayName:      u.DisplayName,
+			Bio:              u.Bio,
+			ProfileURL:       u.ProfileURL,
+			Email:            u.Email,
+			EmailVerified:    u.EmailVerified,
+			Role:             u.Role,
+			IsGuest:          u.IsGuest,
+			TwoFactorEnabled: u.TOTPEnabled,
+			Banned:           u.Banned,
+			SuspendReason:    u.SuspendReason,
+			CreatedAt:        u.CreatedAt,
+			UpdatedAt:        u.UpdatedAt,
+			LastSeenAt:       u.LastSeenAt,
+			Identities:       []ExportIdentity{},
+		},
+		Sessions: []ExportSession{},
+		Friends:  []ExportFriend{},
+		Blocks:   []ExportBlock{},
+		Games:    []ExportGame{},
+		Reports: ExportReports{
+			Filed:    []ExportReport{},
+			Received: []ExportReport{},
+		},
+	}
+	if !u.SuspendedUntil.IsZero() {
+		export.Profile.SuspendedUntil = &u.SuspendedUntil
+	}
+	for _, identity := range u.Identities {
+		export.Profile.Identities = append(export.Profile.Identities, ExportIdentity{
+			Issuer:   identity.Issuer,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: identity.LinkedAt,
+		})
+	}
+
+	sessions, err := sessionService.FindByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		export.Sessions = append(export.Sessions, ExportSession{
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			RevokedAt:  s.RevokedAt,
+		})
+	}
+
+	friendships, err := friendshipService.FindByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := blockService.FindByBlocker(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	filed, err := reportService.FindByReporter(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	received, err := reportService.FindByReported(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []primitive.ObjectID
+	for _, f := range friendships {
+		ids = append(ids, f.Other(u.ID))
+	}
+	for _, b := range blocks {
+		ids = append(ids, b.BlockedID)
+	}
+	for _, r := range filed {
+		ids = append(ids, r.ReportedID)
+	}
+	usernames := make(map[primitive.ObjectID]string)
+	if len(ids) > 0 {
+		found, err := userService.Find(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range found {
+			usernames[other.ID] = other.Username
+		}
+	}
+
+	for _, f := range friendships {
+		export.Friends = append(export.Friends, ExportFriend{
+			Username:   usernames[f.Other(u.ID)],
+			Status:     f.Status,
+			Requested:  f.RequesterID == u.ID,
+			CreatedAt:  f.CreatedAt,
+			AcceptedAt: f.AcceptedAt,
+		})
+	}
+	for _, b := range blocks {
+		export.Blocks = append(export.Blocks, ExportBlock{
+			Username:  usernames[b.BlockedID],
+			CreatedAt: b.CreatedAt,
+		})
+	}
+	for _, r := range filed {
+		report := newExportReport(r)
+		report.Reported = usernames[r.ReportedID]
+		export.Reports.Filed = append(export.Reports.Filed, report)
+	}
+	for _, r := range received {
+		export.Reports.Received = append(export.Reports.Received, newReceivedReport(r))
+	}
+
+	games, err := gameResultService.FindByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range games {
+		game := ExportGame{
+			ID:         g.ID.Hex(),
+			Winner:     g.Winner,
+			Forfeit:    g.Forfeit,
+			StartedAt:  g.StartedAt,
+			FinishedAt: g.FinishedAt,
+			Opponents:  []ExportOpponent{},
+			Answers:    []ExportAnswer{},
+		}
+		for _, p := range g.Players {
+			if p.UserID != u.ID {
+				game.Opponents = append(game.Opponents, ExportOpponent{
+					Username: p.Username,
+					Score:    p.Score,
+				})
+				continue
+			}
+			game.Score = p.Score
+			game.Won = p.Won
+			game.Lost = p.Lost
+			for _, a := range p.Answers {
+				game.Answers = append(game.Answers, ExportAnswer{
+					QuestionID:     a.QuestionID.Hex(),
+					Category:       a.Category,
+					Answer:         a.Answer,
+					Answered:       a.Answered,
+					Correct:        a.Correct,
+					ResponseMillis: a.ResponseMillis,
+				})
+			}
+		}
+		export.Games = append(export.Games, game)
+	}
+	return export, nil
+}
+
+// newExportReport returns the report without the reporter, who is only known to moderators
+func newExportReport(r *models.Report) ExportReport {
+	report := ExportReport{
+		ID:         r.ID.Hex(),
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ReviewedAt: r.ReviewedAt,
+		CreatedAt:  r.CreatedAt,
+	}
+	if r.GameID != nil {
+		report.GameID = r.GameID.Hex()
+	}
+	return report
+}
+
+// newReceivedReport returns the reason, status and date of a report against the user.
+// The details and the game would tell the user who reported them.
+func newReceivedReport(r *models.Report) ExportReport {
+	return ExportReport{
+		Reason:    r.Reason,
+		Status:    r.Status,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// DataExport is everything stored about a user
+type DataExport struct {
+	ExportedAt time.Time       `json:"exportedAt"`
+	Profile    ExportProfile   `json:"profile"`
+	Sessions   []ExportSession `json:"sessions"`
+	Friends    []ExportFriend  `json:"friends"`
+	Blocks     []ExportBlock   `json:"blocks"`
+	Games      []ExportGame    `json:"games"`
+	Reports    ExportReports   `json:"reports"`
+}
+
+// ExportProfile is the account of the user
+type ExportProfile struct {
+	Username         string           `json:"username"`
+	DisplayName      string           `json:"displayName"`
+	Bio              string           `json:"bio"`
+	ProfileURL       string           `json:"profileURL"`
+	Email            string           `json:"email"`
+	EmailVerified    bool             `json:"emailVerified"`
+	Role             rbac.Role        `json:"role"`
+	IsGuest          bool             `json:"isGuest"`
+	TwoFactorEnabled bool             `json:"twoFactorEnabled"`
+	Banned           bool             `json:"banned"`
+	SuspendedUntil   *time.Time       `json:"suspendedUntil,omitempty"`
+	SuspendReason    string           `json:"suspendReason,omitempty"`
+	Identities       []ExportIdentity `json:"identities"`
+	CreatedAt        time.Time        `json:"createdAt"`
+	UpdatedAt        time.Time        `json:"updatedAt"`
+	LastSeenAt       time.Time        `json:"lastSeenAt"`
+}
+
+// ExportIdentity is an external account linked to the user
+type ExportIdentity struct {
+	Issuer   string    `json:"issuer"`
+	Subject  string    `json:"subject"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
+
+// ExportSession is a device the user logged in on
+type ExportSession struct {
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ExportFriend is a friend or a friend request of the user
+type ExportFriend struct {
+	Username   string                  `json:"username"`
+	Status     models.FriendshipStatus `json:"status"`
+	Requested  bool                    `json:"requested"`
+	CreatedAt  time.Time               `json:"createdAt"`
+	AcceptedAt time.Time               `json:"acceptedAt"`
+}
+
+// ExportBlock is a user blocked by the user
+type ExportBlock struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ExportGame is a game the user played with their answers
+type ExportGame struct {
+	ID         string           `json:"id"`
+	Winner     string           `json:"winner"`
+	Forfeit    bool             `json:"forfeit"`
+	Score      float64          `json:"score"`
+	Won        bool             `json:"won"`
+	Lost       bool             `json:"lost"`
+	Opponents  []ExportOpponent `json:"opponents"`
+	Answers    []ExportAnswer   `json:"answers"`
+	StartedAt  time.Time        `json:"startedAt"`
+	FinishedAt time.Time        `json:"finishedAt"`
+}
+
+// ExportAnswer is how the user answered a question of a game
+type ExportAnswer struct {
+	QuestionID     string `json:"questionId"`
+	Category       string `json:"category"`
+	Answer         string `json:"answer"`
+	Answered       bool   `json:"answered"`
+	Correct        bool   `json:"correct"`
+	ResponseMillis int64  `json:"responseMillis"`
+}
+
+// ExportOpponent is the other player of a game
+type ExportOpponent struct {
+	Username string  `json:"username"`
+	Score    float64 `json:"score"`
+}
+
+// ExportReports are the reports filed by and against the user
+type ExportReports struct {
+	Filed    []ExportReport `json:"filed"`
+	Received []ExportReport `json:"received"`
+}
+
+// ExportReport is a report filed by or against the user. Reports against the user only have their reason,
+// status and date.
+type ExportReport struct {
+	ID         string              `json:"id,omitempty"`
+	Reported   string              `json:"reported,omitempty"`
+	Reason     models.ReportReason `json:"reason"`
+	Details    string              `json:"details,omitempty"`
+	GameID     string              `json:"gameId,omitempty"`
+	Status     models.ReportStatus `json:"status"`
+	ReviewedAt *time.Time          `json:"reviewedAt,omitempty"`
+	CreatedAt  time.Time           `json:"createdAt"`
+}
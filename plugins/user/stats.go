@@ -0,0 +1,101 @@
+package user
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	blockService "github.com/acha-bill/quizzer_backend/packages/dblayer/block"
+	gameResultService "github.com/acha-bill/quizzer_backend/packages/dblayer/gameresult"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// @Summary get the public profile and statistics of a player
+// @Description winRate is wins over games played. accuracy is correct answers over questions asked.
+// @Description Players that blocked each other cannot see each other's profile.
+// @Produce  application/json
+// @Router /user/{username} [get]
+// @Tags User
+// @Param username path string true "username"
+// @Success 200 {object} PublicProfileResponse
+// @Failure 404 {object} PublicProfileResponse
+func getPublicProfile(ctx echo.Context) error {
+	userID, err := primitive.ObjectIDFromHex(common.GetClaims(ctx).Id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, PublicProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	u := userService.FindByUsername(ctx.Param("username"))
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, PublicProfileResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	blocked, err := blockService.IsBlocked(userID, u.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, PublicProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	if blocked {
+		return ctx.JSON(http.StatusNotFound, PublicProfileResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+
+	stats, err := gameResultService.Stats(u.ID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, PublicProfileResponse{
+			Error: err.Error(),
+		})
+	}
+
+	res := PublicProfileResponse{
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		ProfileURL:  u.ProfileURL,
+		IsGuest:     u.IsGuest,
+		CreatedAt:   u.CreatedAt,
+		Stats: StatsResponse{
+			Played:             stats.Played,
+			Wins:               stats.Wins,
+			Losses:             stats.Losses,
+			MeanResponseMillis: stats.MeanResponseMillis,
+			FavouriteCategory:  stats.FavouriteCategory,
+		},
+	}
+	if stats.Played > 0 {
+		res.Stats.WinRate = float64(stats.Wins) / float64(stats.Played)
+	}
+	if stats.Questions > 0 {
+		res.Stats.Accuracy = float64(stats.Correct) / float64(stats.Questions)
+	}
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// StatsResponse represents the statistics of a player
+type StatsResponse struct {
+	Played             int64   `json:"played"`
+	Wins               int64   `json:"wins"`
+	Losses             int64   `json:"losses"`
+	WinRate            float64 `json:"winRate"`
+	Accuracy           float64 `json:"accuracy"`
+	MeanResponseMillis float64 `json:"meanResponseMillis"`
+	FavouriteCategory  string  `json:"favouriteCategory,omitempty"`
+}
+
+// PublicProfileResponse represents the public profile of a player
+type PublicProfileResponse struct {
+	Error       string        `json:"error,omitempty"`
+	Username    string        `json:"username,omitempty"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Bio         string        `json:"bio,omitempty"`
+	ProfileURL  string        `json:"profileURL,omitempty"`
+	IsGuest     bool          `json:"isGuest,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	Stats       StatsResponse `json:"stats"`
+}
@@ -0,0 +1,218 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acha-bill/quizzer_backend/common"
+	"github.com/acha-bill/quizzer_backend/models"
+	userService "github.com/acha-bill/quizzer_backend/packages/dblayer/user"
+	"github.com/acha-bill/quizzer_backend/packages/rbac"
+	"github.com/acha-bill/quizzer_backend/plugins"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// PluginName defines the name of the plugin
+	PluginName = "user"
+	// maxDisplayNameLength is the maximum number of characters of a display name
+	maxDisplayNameLength = 40
+	// maxBioLength is the maximum number of characters of a bio
+	maxBioLength = 300
+)
+
+var (
+	plugin *User
+	once   sync.Once
+	// ErrUserNotFound is returned when the user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrDisplayNameTooLong is returned when the display name is longer than maxDisplayNameLength
+	ErrDisplayNameTooLong = errors.New("display name is too long")
+	// ErrBioTooLong is returned when the bio is longer than maxBioLength
+	ErrBioTooLong = errors.New("bio is too long")
+	// ErrInvalidProfileURL is returned when the avatar is not an http(s) URL
+	ErrInvalidProfileURL = errors.New("profile URL must be an http or https URL")
+)
+
+// User structure
+type User struct {
+	name     string
+	handlers []*plugins.PluginHandler
+}
+
+// AddHandler Method definition from interface
+func (plugin *User) AddHandler(method string, path string, handler func(echo.Context) error, permission ...rbac.Permission) {
+	pluginHandler := &plugins.PluginHandler{
+		Path:       path,
+		Handler:    handler,
+		Method:     method,
+		Permission: rbac.PermissionAuthenticated,
+	}
+	if len(permission) > 0 {
+		pluginHandler.Permission = permission[0]
+	}
+	plugin.handlers = append(plugin.handlers, pluginHandler)
+}
+
+// Handlers Method definition from interface
+func (plugin *User) Handlers() []*plugins.PluginHandler {
+	return plugin.handlers
+}
+
+// Name defines the name of the plugin
+func (plugin *User) Name() string {
+	return plugin.name
+}
+
+// NewPlugin returns the new plugin
+func NewPlugin() *User {
+	plugin := &User{
+		name: PluginName,
+	}
+	return plugin
+}
+
+// Plugin returns an instance of the plugin
+func Plugin() *User {
+	once.Do(func() {
+		plugin = NewPlugin()
+	})
+	return plugin
+}
+
+func init() {
+	user := Plugin()
+	user.AddHandler(http.MethodGet, "/me", getProfile)
+	user.AddHandler(http.MethodPut, "/me", updateProfile)
+	user.AddHandler(http.MethodDelete, "/me", deleteAccount)
+	user.AddHandler(http.MethodGet, "/me/export", exportData)
+	user.AddHandler(http.MethodPost, "/me/avatar", uploadAvatar)
+	user.AddHandler(http.MethodGet, "/:username", getPublicProfile)
+}
+
+// @Summary get the profile of the user
+// @Produce  application/json
+// @Router /user/me [get]
+// @Tags User
+// @Success 200 {object} ProfileResponse
+func getProfile(ctx echo.Context) error {
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, ProfileResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, newProfileResponse(u))
+}
+
+// @Summary update the profile of the user
+// @Description Fields that are omitted are left unchanged. An empty profileURL removes the avatar.
+// @Accept  application/json
+// @Produce  application/json
+// @Router /user/me [put]
+// @Tags User
+// @Param profile body UpdateProfileRequest true "profile"
+// @Success 200 {object} ProfileResponse
+func updateProfile(ctx echo.Context) error {
+	var req UpdateProfileRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+
+	u := userService.FindById(common.GetClaims(ctx).Id)
+	if u.ID.IsZero() {
+		return ctx.JSON(http.StatusNotFound, ProfileResponse{
+			Error: ErrUserNotFound.Error(),
+		})
+	}
+
+	if req.DisplayName != nil {
+		u.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Bio != nil {
+		u.Bio = strings.TrimSpace(*req.Bio)
+	}
+	if err := validateProfile(u.DisplayName, u.Bio); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+	if err := userService.SetProfile(u.ID, u.DisplayName, u.Bio); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if req.ProfileURL != nil && *req.ProfileURL != u.ProfileURL {
+		profileURL := strings.TrimSpace(*req.ProfileURL)
+		if profileURL != "" && !isHTTPURL(profileURL) {
+			return ctx.JSON(http.StatusBadRequest, ProfileResponse{
+				Error: ErrInvalidProfileURL.Error(),
+			})
+		}
+		if err := setAvatar(u, profileURL); err != nil {
+			return ctx.JSON(http.StatusInternalServerError, ProfileResponse{
+				Error: err.Error(),
+			})
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, newProfileResponse(u))
+}
+
+func validateProfile(displayName string, bio string) error {
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		return ErrDisplayNameTooLong
+	}
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return ErrBioTooLong
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func newProfileResponse(u *models.User) ProfileResponse {
+	return ProfileResponse{
+		Username:      u.Username,
+		DisplayName:   u.DisplayName,
+		Bio:           u.Bio,
+		ProfileURL:    u.ProfileURL,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Role:          u.Role,
+		IsGuest:       u.IsGuest,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+// UpdateProfileRequest represents the Request object for UpdateProfile
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"displayName"`
+	Bio         *string `json:"bio"`
+	ProfileURL  *string `json:"profileURL"`
+}
+
+// ProfileResponse represents the profile of the user
+type ProfileResponse struct {
+	Error         string    `json:"error,omitempty"`
+	Username      string    `json:"username,omitempty"`
+	DisplayName   string    `json:"displayName,omitempty"`
+	Bio           string    `json:"bio,omitempty"`
+	ProfileURL    string    `json:"profileURL,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"emailVerified,omitempty"`
+	Role          rbac.Role `json:"role,omitempty"`
+	IsGuest       bool      `json:"isGuest,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}